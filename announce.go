@@ -0,0 +1,91 @@
+package orion
+
+import (
+	"os"
+	"time"
+
+	"github.com/gig/orion-go-sdk/logger"
+	"github.com/gig/orion-go-sdk/registry"
+)
+
+// Announcement of the service on the registry subject
+func (s *Service) Announcement() registry.Announcement {
+	host, _ := os.Hostname()
+
+	routes := []string{}
+	for _, info := range s.Routes() {
+		routes = append(routes, info.Route)
+	}
+
+	return registry.Announcement{
+		Name:      s.Name,
+		ID:        s.ID,
+		Version:   s.Version,
+		Routes:    routes,
+		Host:      host,
+		Interval:  int(s.AnnounceInterval / time.Millisecond),
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+}
+
+// announce the service every AnnounceInterval until it leaves
+func (s *Service) announce() {
+	if s.AnnounceInterval <= 0 {
+		return
+	}
+
+	s.publishAnnouncement(s.Announcement())
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	s.stopAnnouncing = func() {
+		close(stop)
+		<-stopped
+	}
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(s.AnnounceInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.publishAnnouncement(s.Announcement())
+			}
+		}
+	}()
+}
+
+// leave the registry, so the instance is not listed until it goes stale. The
+// announcements stop first, so none goes out after the leaving one
+func (s *Service) leave() {
+	if s.stopAnnouncing == nil {
+		return
+	}
+
+	s.stopAnnouncing()
+	s.stopAnnouncing = nil
+
+	a := s.Announcement()
+	a.Leaving = true
+	s.publishAnnouncement(a)
+	s.flushTransport()
+}
+
+func (s *Service) publishAnnouncement(a registry.Announcement) {
+	b, err := s.Codec.Encode(a)
+	if err == nil {
+		err = s.Transport.Publish(registry.Subject, b)
+	}
+	if err != nil {
+		s.Logger.
+			CreateMessage("announcement failed").
+			SetLevel(logger.WARNING).
+			SetParams(err.Error()).
+			Send()
+	}
+}
@@ -0,0 +1,91 @@
+package orion
+
+import (
+	"context"
+	"sync"
+
+	oerror "github.com/gig/orion-go-sdk/error"
+	"github.com/gig/orion-go-sdk/interfaces"
+)
+
+// Invocation pairs a request with the response it is decoded into
+type Invocation struct {
+	Request  interfaces.Request
+	Response interfaces.Response
+}
+
+// CallAsync works the same as CallContext but it does not block. The returned
+// channel receives the response once the call is done
+func (s *Service) CallAsync(ctx context.Context, req interfaces.Request, raw interface{}) <-chan interfaces.Response {
+	res, ok := raw.(interfaces.Response)
+	checkResponseCast(ok)
+
+	loc := oerror.GenerateLOC(1)
+	done := make(chan interfaces.Response, 1)
+
+	go func() {
+		s.call(ctx, req, res, loc)
+		done <- res
+	}()
+
+	return done
+}
+
+// CallAll issues the calls concurrently and waits for all of them. Every call
+// respects the timeout of its request and errors are set on its response
+func (s *Service) CallAll(ctx context.Context, calls ...Invocation) {
+	loc := oerror.GenerateLOC(1)
+
+	var wg sync.WaitGroup
+	wg.Add(len(calls))
+
+	for _, c := range calls {
+		go func(c Invocation) {
+			defer wg.Done()
+			s.call(ctx, c.Request, c.Response, loc)
+		}(c)
+	}
+
+	wg.Wait()
+}
+
+// CallFirst issues the calls concurrently and returns as soon as n of them
+// succeeded, cancelling the rest with an ORION_CANCELED error. It returns the
+// successful calls in the order they were replied, which are less than n when
+// not enough calls succeeded. No call is issued when n is not positive and n
+// is limited to the number of calls
+func (s *Service) CallFirst(ctx context.Context, n int, calls ...Invocation) []Invocation {
+	if n <= 0 {
+		return nil
+	}
+	if n > len(calls) {
+		n = len(calls)
+	}
+
+	loc := oerror.GenerateLOC(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan Invocation, len(calls))
+
+	for _, c := range calls {
+		go func(c Invocation) {
+			s.call(ctx, c.Request, c.Response, loc)
+			done <- c
+		}(c)
+	}
+
+	succeeded := make([]Invocation, 0, n)
+	for range calls {
+		c := <-done
+		if c.Response.GetError() == nil && len(succeeded) < n {
+			succeeded = append(succeeded, c)
+			if len(succeeded) == n {
+				cancel()
+			}
+		}
+	}
+
+	return succeeded
+}
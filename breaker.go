@@ -0,0 +1,214 @@
+package orion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	oerror "github.com/gig/orion-go-sdk/error"
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/go-chi/chi"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 5 * time.Second
+)
+
+// Circuit states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreakerOptions for outgoing calls
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// circuit of a route. Defaults to 5
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a probe call is
+	// let through. Defaults to 5s
+	OpenTimeout time.Duration
+	// FailureCodes are the error codes counted as failures. Defaults to
+	// ORION_TRANSPORT and ORION_OVERLOADED
+	FailureCodes []string
+}
+
+func (o CircuitBreakerOptions) failure(code string) bool {
+	codes := o.FailureCodes
+	if len(codes) == 0 {
+		codes = []string{"ORION_TRANSPORT", "ORION_OVERLOADED"}
+	}
+
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// CircuitStatus of a destination route
+type CircuitStatus struct {
+	Route    string    `json:"route"`
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+	OpenedAt time.Time `json:"openedAt"`
+}
+
+type circuit struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// allow reports whether a call can go through. Once the open timeout is over
+// the circuit becomes half-open and a single probe call is allowed
+func (c *circuit) allow(options CircuitBreakerOptions, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case CircuitOpen:
+		timeout := options.OpenTimeout
+		if timeout <= 0 {
+			timeout = defaultOpenTimeout
+		}
+		if now.Sub(c.openedAt) < timeout {
+			return false
+		}
+		c.state = CircuitHalfOpen
+		return true
+	case CircuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// record the outcome of a call that was allowed
+func (c *circuit) record(options CircuitBreakerOptions, failed bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !failed {
+		c.state = CircuitClosed
+		c.failures = 0
+		return
+	}
+
+	threshold := options.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= threshold {
+		c.state = CircuitOpen
+		c.openedAt = now
+	}
+}
+
+func (c *circuit) status(route string) CircuitStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := CircuitStatus{
+		Route:    route,
+		State:    c.state,
+		Failures: c.failures,
+	}
+	if c.state != CircuitClosed {
+		status.OpenedAt = c.openedAt
+	}
+	return status
+}
+
+type breakers struct {
+	mu       sync.Mutex
+	options  *CircuitBreakerOptions
+	circuits map[string]*circuit
+}
+
+// EnableCircuitBreaker for the calls made by the service. Each destination
+// route has its own circuit. While it is open, calls fail right away with an
+// ORION_CIRCUIT_OPEN error instead of waiting for the timeout
+func (s *Service) EnableCircuitBreaker(options CircuitBreakerOptions) {
+	s.breakers.mu.Lock()
+	defer s.breakers.mu.Unlock()
+
+	s.breakers.options = &options
+}
+
+// Circuits returns the status of the circuit of every route called so far
+func (s *Service) Circuits() []CircuitStatus {
+	s.breakers.mu.Lock()
+	defer s.breakers.mu.Unlock()
+
+	statuses := make([]CircuitStatus, 0, len(s.breakers.circuits))
+	for route, c := range s.breakers.circuits {
+		statuses = append(statuses, c.status(route))
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Route < statuses[j].Route
+	})
+
+	return statuses
+}
+
+func (s *Service) circuit(route string) (*circuit, CircuitBreakerOptions, bool) {
+	s.breakers.mu.Lock()
+	defer s.breakers.mu.Unlock()
+
+	if s.breakers.options == nil {
+		return nil, CircuitBreakerOptions{}, false
+	}
+
+	if s.breakers.circuits == nil {
+		s.breakers.circuits = map[string]*circuit{}
+	}
+
+	c, ok := s.breakers.circuits[route]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		s.breakers.circuits[route] = c
+	}
+
+	return c, *s.breakers.options, true
+}
+
+// breaking wraps the invoker with the circuit of the route
+func (s *Service) breaking(route string, loc oerror.LineOfCode, next Invoker) Invoker {
+	c, options, ok := s.circuit(route)
+	if !ok {
+		return next
+	}
+
+	return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+		if !c.allow(options, time.Now()) {
+			res.SetError(oerror.New("ORION_CIRCUIT_OPEN").SetMessage("the circuit for " + route + " is open").SetLineOfCode(loc))
+			return
+		}
+
+		next(ctx, req, res)
+
+		err := res.GetError()
+		c.record(options, err != nil && options.failure(err.Code), time.Now())
+	}
+}
+
+// installCircuits exposes the status of the circuits on the health HTTP server
+func (s *Service) installCircuits(router chi.Router) {
+	router.Get("/circuits", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.Circuits()); err != nil {
+			s.logHTTPError(r, err)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package orion
+
+import (
+	"context"
+
+	oerror "github.com/gig/orion-go-sdk/error"
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/response"
+)
+
+const broadcastPrefix = "_BROADCAST."
+
+// broadcastReply wraps the reply of an instance to a broadcast call
+type broadcastReply struct {
+	Instance string `msgpack:"instance"`
+	Response []byte `msgpack:"response"`
+}
+
+// broadcastRoute every instance subscribes to, without a queue group
+func broadcastRoute(route string) string {
+	return broadcastPrefix + route
+}
+
+// handleBroadcast subscribes the handler of the route so every instance
+// replies to the broadcast calls, telling its ID
+func (s *Service) handleBroadcast(route string, handler func([]byte, func([]byte))) {
+	s.Transport.Handle(broadcastRoute(route), "", func(data []byte, reply func([]byte)) {
+		handler(data, func(b []byte) {
+			wrapped, err := s.Codec.Encode(broadcastReply{Instance: s.ID, Response: b})
+			if err == nil {
+				reply(wrapped)
+			}
+		})
+	})
+}
+
+// CallBroadcast sends the request to every instance of the destination service
+// and returns their responses keyed by instance ID. It waits for the expected
+// number of replies, or until the request timeout when expected is 0. The
+// factory creates the response every reply is decoded into. The error tells
+// the call failed, e.g. it could not be sent, as opposed to no instance
+// replying. Interceptors and circuit breakers apply, retries, hedging,
+// coalescing and the cache do not
+func (s *Service) CallBroadcast(ctx context.Context, req interfaces.Request, factory func() interfaces.Response, expected int) (map[string]interfaces.Response, error) {
+	loc := oerror.GenerateLOC(1)
+	route := replaceOmitEmpty(req.GetPath(), "/", ".")
+	responses := map[string]interfaces.Response{}
+
+	invoke := s.broadcastInvoker(loc, factory, expected, responses)
+	invoke = s.breaking(route, loc, invoke)
+	invoke = s.intercepted(route, invoke)
+
+	res := response.New()
+	s.send(ctx, route, req, res, loc, invoke)
+	if err := res.GetError(); err != nil {
+		return responses, err
+	}
+	return responses, nil
+}
+
+// broadcastInvoker returns the innermost Invoker of a broadcast call. The
+// replies are added to responses and res only gets the error of the call
+func (s *Service) broadcastInvoker(loc oerror.LineOfCode, factory func() interfaces.Response, expected int, responses map[string]interfaces.Response) Invoker {
+	return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+		encoded, err := s.Codec.Encode(req)
+		if err != nil {
+			res.SetError(oerror.New("ORION_ENCODE").SetMessage(err.Error()).SetLineOfCode(loc))
+			return
+		}
+
+		timeout := s.callTimeout(ctx, req)
+		if timeout <= 0 {
+			res.SetError(oerror.New("ORION_DEADLINE_EXCEEDED").SetMessage("the deadline of the request is over").SetLineOfCode(loc))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		route := broadcastRoute(replaceOmitEmpty(req.GetPath(), "/", "."))
+		err = s.requestMany(ctx, route, encoded, func(b []byte) bool {
+			var reply broadcastReply
+			if s.Codec.Decode(b, &reply) != nil {
+				return true
+			}
+
+			res := factory()
+			if err := s.Codec.Decode(reply.Response, res); err != nil {
+				res.SetError(oerror.New("ORION_DECODE").SetMessage(err.Error()))
+			}
+
+			responses[reply.Instance] = res
+			return expected <= 0 || len(responses) < expected
+		})
+
+		// the replies are gathered until the timeout, so it is not an error
+		if err != nil && ctx.Err() != context.DeadlineExceeded {
+			code := "ORION_TRANSPORT"
+			if ctx.Err() == context.Canceled {
+				code = "ORION_CANCELED"
+			}
+			res.SetError(oerror.New(code).SetMessage(err.Error()).SetLineOfCode(loc))
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package orion
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+)
+
+const defaultCacheSize = 1000
+
+// CacheOptions for the responses cached by the caller
+type CacheOptions struct {
+	// Size is the maximum number of responses kept. The least recently used
+	// ones are evicted first. Defaults to 1000
+	Size int
+	// StaleWhileRevalidate is how long an expired response is still served
+	// while it is refreshed in the background. Disabled by default
+	StaleWhileRevalidate time.Duration
+}
+
+type cacheState int
+
+const (
+	cacheMiss cacheState = iota
+	cacheFresh
+	cacheStale
+)
+
+type cacheEntry struct {
+	key        string
+	response   []byte
+	expires    time.Time
+	refreshing bool
+}
+
+type responseCache struct {
+	mu      sync.Mutex
+	options CacheOptions
+	ttls    map[string]time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// EnableCache sets the options of the response cache. Only the paths added
+// with CachePath are cached
+func (s *Service) EnableCache(options CacheOptions) {
+	s.cache.mu.Lock()
+	defer s.cache.mu.Unlock()
+
+	s.cache.options = options
+	s.cache.evict()
+}
+
+// CachePath caches the successful responses of the calls to the given
+// destination path, e.g. "/config/get", for ttl. Calls are keyed by path and
+// params. The callee can override the ttl with a cache-control response meta,
+// either "no-store" or "max-age=<seconds>"
+func (s *Service) CachePath(path string, ttl time.Duration) {
+	s.cache.mu.Lock()
+	defer s.cache.mu.Unlock()
+
+	if s.cache.ttls == nil {
+		s.cache.ttls = map[string]time.Duration{}
+	}
+
+	s.cache.ttls[replaceOmitEmpty(path, "/", ".")] = ttl
+}
+
+func (c *responseCache) ttl(route string) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttl, ok := c.ttls[route]
+	return ttl, ok && ttl > 0
+}
+
+// get the response for the key. A stale response is returned only to the
+// first caller, which is expected to refresh it
+func (c *responseCache) get(key string, now time.Time) ([]byte, cacheState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, cacheMiss
+	}
+
+	entry := el.Value.(*cacheEntry)
+	switch {
+	case now.Before(entry.expires):
+		c.lru.MoveToFront(el)
+		return entry.response, cacheFresh
+	case now.Before(entry.expires.Add(c.options.StaleWhileRevalidate)):
+		c.lru.MoveToFront(el)
+		if entry.refreshing {
+			return entry.response, cacheFresh
+		}
+		entry.refreshing = true
+		return entry.response, cacheStale
+	default:
+		c.remove(el)
+		return nil, cacheMiss
+	}
+}
+
+func (c *responseCache) set(key string, response []byte, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]*list.Element{}
+		c.lru = list.New()
+	}
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:      key,
+		response: response,
+		expires:  expires,
+	})
+	c.evict()
+}
+
+// delete the entry of the key, e.g. when the callee asked not to store it
+func (c *responseCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// refreshed lets another caller refresh the stale entry after a failed refresh
+func (c *responseCache) refreshed(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).refreshing = false
+	}
+}
+
+func (c *responseCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+func (c *responseCache) evict() {
+	size := c.options.Size
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+
+	for c.lru != nil && c.lru.Len() > size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// caching serves the calls to the cached routes from the cache
+func (s *Service) caching(route string, next Invoker) Invoker {
+	ttl, ok := s.cache.ttl(route)
+	if !ok {
+		return next
+	}
+
+	return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+		key, err := s.cacheKey(route, req)
+		if err != nil {
+			next(ctx, req, res)
+			return
+		}
+
+		b, state := s.cache.get(key, time.Now())
+		if state != cacheMiss && s.Codec.Decode(b, res) == nil {
+			if state == cacheStale {
+				go s.revalidate(key, ttl, cloneRequest(req), newResponse(res), next)
+			}
+			return
+		}
+
+		next(ctx, req, res)
+		s.cacheResponse(key, ttl, res)
+	}
+}
+
+func (s *Service) revalidate(key string, ttl time.Duration, req interfaces.Request, res interfaces.Response, next Invoker) {
+	next(s.ctx, req, res)
+	if !s.cacheResponse(key, ttl, res) {
+		s.cache.refreshed(key)
+	}
+}
+
+// cacheResponse stores the response unless it failed or the callee asked not to
+func (s *Service) cacheResponse(key string, ttl time.Duration, res interfaces.Response) bool {
+	if res.GetError() != nil {
+		return false
+	}
+
+	var control string
+	if res, ok := res.(interfaces.MetaResponse); ok {
+		control = res.GetMetaProp("cache-control")
+	}
+	switch {
+	case control == "no-store":
+		s.cache.delete(key)
+		return false
+	case strings.HasPrefix(control, "max-age="):
+		seconds, err := strconv.Atoi(strings.TrimPrefix(control, "max-age="))
+		if err != nil || seconds <= 0 {
+			s.cache.delete(key)
+			return false
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	b, err := s.Codec.Encode(res)
+	if err != nil {
+		return false
+	}
+
+	s.cache.set(key, b, time.Now().Add(ttl))
+	return true
+}
+
+// cacheKey of a call: its route and its encoded params
+func (s *Service) cacheKey(route string, req interfaces.Request) (string, error) {
+	params, ok := requestParams(req).([]byte)
+	if !ok {
+		var err error
+		params, err = s.Codec.Encode(requestParams(req))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return route + "\x00" + string(params), nil
+}
+
+// cloneRequest copies the request, and its meta, so it can be sent again in
+// the background while the caller keeps using the original
+func cloneRequest(req interfaces.Request) interfaces.Request {
+	v := reflect.ValueOf(req)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return req
+	}
+
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+
+	if meta := c.Elem().FieldByName("Meta"); meta.IsValid() && meta.CanSet() && meta.Kind() == reflect.Map {
+		meta.Set(reflect.MakeMap(meta.Type()))
+	}
+
+	clone := c.Interface().(interfaces.Request)
+	clone.SetMeta(req.GetMeta())
+	return clone
+}
+
+// newResponse of the same type as res
+func newResponse(res interfaces.Response) interfaces.Response {
+	t := reflect.TypeOf(res)
+	if t.Kind() != reflect.Ptr {
+		return res
+	}
+	return reflect.New(t.Elem()).Interface().(interfaces.Response)
+}
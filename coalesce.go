@@ -0,0 +1,148 @@
+package orion
+
+import (
+	"context"
+	"sync"
+
+	oerror "github.com/gig/orion-go-sdk/error"
+	"github.com/gig/orion-go-sdk/interfaces"
+)
+
+// flight is a call shared by identical concurrent calls
+type flight struct {
+	done     chan struct{}
+	response []byte
+}
+
+type coalescing struct {
+	mu      sync.Mutex
+	service bool
+	paths   map[string]bool
+	flights map[string]*flight
+	saved   map[string]uint64
+}
+
+// Coalesce identical concurrent calls made by the service, see CoalescePath
+func (s *Service) Coalesce() {
+	s.coalescing.mu.Lock()
+	defer s.coalescing.mu.Unlock()
+
+	s.coalescing.service = true
+}
+
+// CoalescePath coalesces the concurrent calls to the given destination path
+// with the same params into a single round trip. The response is shared with
+// every caller, so the meta of the requests other than the first is ignored
+func (s *Service) CoalescePath(path string) {
+	s.coalescing.mu.Lock()
+	defer s.coalescing.mu.Unlock()
+
+	if s.coalescing.paths == nil {
+		s.coalescing.paths = map[string]bool{}
+	}
+	s.coalescing.paths[replaceOmitEmpty(path, "/", ".")] = true
+}
+
+// Coalesced returns how many calls were saved by coalescing per route
+func (s *Service) Coalesced() map[string]uint64 {
+	s.coalescing.mu.Lock()
+	defer s.coalescing.mu.Unlock()
+
+	counts := make(map[string]uint64, len(s.coalescing.saved))
+	for route, count := range s.coalescing.saved {
+		counts[route] = count
+	}
+	return counts
+}
+
+func (c *coalescing) enabled(route string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.service || c.paths[route]
+}
+
+// join the flight of the key. It returns false when the caller has to make
+// the call, and land the flight afterwards
+func (c *coalescing) join(route, key string) (*flight, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f, ok := c.flights[key]; ok {
+		if c.saved == nil {
+			c.saved = map[string]uint64{}
+		}
+		c.saved[route]++
+		return f, true
+	}
+
+	if c.flights == nil {
+		c.flights = map[string]*flight{}
+	}
+
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	return f, false
+}
+
+func (c *coalescing) land(key string, f *flight, response []byte) {
+	c.mu.Lock()
+	delete(c.flights, key)
+	c.mu.Unlock()
+
+	f.response = response
+	close(f.done)
+}
+
+// coalesced shares the response of a call with the identical calls made
+// while it is in flight. When the shared call was cancelled by its caller,
+// or its response cannot be shared, the others make the call themselves
+func (s *Service) coalesced(route string, next Invoker) Invoker {
+	if !s.coalescing.enabled(route) {
+		return next
+	}
+
+	return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+		key, err := s.cacheKey(route, req)
+		if err != nil {
+			next(ctx, req, res)
+			return
+		}
+
+		f, shared := s.coalescing.join(route, key)
+		if !shared {
+			next(ctx, req, res)
+
+			var b []byte
+			if err := res.GetError(); err == nil || err.Code != "ORION_CANCELED" {
+				b, _ = s.Codec.Encode(res)
+			}
+			s.coalescing.land(key, f, b)
+			return
+		}
+
+		// the wait is bounded by the timeout of this call, the flight keeps
+		// going for the others
+		timeout := s.callTimeout(ctx, req)
+		if timeout <= 0 {
+			res.SetError(oerror.New("ORION_DEADLINE_EXCEEDED").SetMessage("the deadline of the request is over"))
+			return
+		}
+
+		wait, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		select {
+		case <-f.done:
+			if f.response == nil || s.Codec.Decode(f.response, res) != nil {
+				next(ctx, req, res)
+			}
+		case <-wait.Done():
+			code := "ORION_TRANSPORT"
+			if wait.Err() == context.Canceled {
+				code = "ORION_CANCELED"
+			}
+			res.SetError(oerror.New(code).SetMessage(wait.Err().Error()))
+		}
+	}
+}
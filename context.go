@@ -0,0 +1,68 @@
+package orion
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/request"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+type traceIDKey struct{}
+
+// instanceKey carries the instance a call is sent to
+type instanceKey struct{}
+
+type matchedRouteKey struct{}
+
+// WithTraceID returns a copy of the context carrying the trace ID
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceID carried by the context. Handlers receive the x-trace-id of the
+// request through it
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// MatchedRoute carried by the context. Handlers receive the route the request
+// was sent to through it, e.g. admin.users.list for a handler on admin/>
+func MatchedRoute(ctx context.Context) string {
+	route, _ := ctx.Value(matchedRouteKey{}).(string)
+	return route
+}
+
+// handlerContext for a request received at the given time. It expires when
+// the caller stops waiting for the reply, or at the x-deadline of the request
+// if it is earlier, and is cancelled when the service is closed
+func (s *Service) handlerContext(req interfaces.Request, received time.Time) (context.Context, context.CancelFunc) {
+	deadline := received.Add(time.Duration(s.getTimeout(req)) * time.Millisecond)
+	if budget, ok := request.Deadline(req); ok && budget.Before(deadline) {
+		deadline = budget
+	}
+	ctx, cancel := context.WithDeadline(s.ctx, deadline)
+	return requestContext(ctx, req), cancel
+}
+
+// streamContext for a stream request. The request timeout bounds the wait
+// for every chunk instead of the whole stream, so it only expires at the
+// x-deadline of the request, and is cancelled when the service is closed
+func (s *Service) streamContext(req interfaces.Request, received time.Time) (context.Context, context.CancelFunc) {
+	if budget, ok := request.Deadline(req); ok {
+		ctx, cancel := context.WithDeadline(s.ctx, budget)
+		return requestContext(ctx, req), cancel
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	return requestContext(ctx, req), cancel
+}
+
+// requestContext carries the matched route and the trace ID of the request
+func requestContext(ctx context.Context, req interfaces.Request) context.Context {
+	ctx = context.WithValue(ctx, matchedRouteKey{}, replaceOmitEmpty(req.GetPath(), "/", "."))
+	return WithTraceID(ctx, req.GetID())
+}
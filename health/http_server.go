@@ -14,12 +14,18 @@ import (
 	and call InstallHealthcheck instead.
 */
 
-func StartHTTPServer(addr string) *http.Server {
+// StartHTTPServer with the healthcheck endpoint. The installers can mount
+// additional endpoints on the router
+func StartHTTPServer(addr string, installers ...func(chi.Router)) *http.Server {
 	r := chi.NewRouter()
 	httpServer := http.Server{Addr: addr, Handler: r}
 
 	InstallHealthcheck(r, "/healthcheck")
 
+	for _, install := range installers {
+		install(r)
+	}
+
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
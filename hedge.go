@@ -0,0 +1,115 @@
+package orion
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+)
+
+type hedges struct {
+	mu         sync.RWMutex
+	idempotent map[string]bool
+	delays     map[string]time.Duration
+}
+
+// DeclareIdempotent marks the given destination paths, e.g. "/calc/sum", as
+// safe to be sent more than once. Only idempotent paths are hedged
+func (s *Service) DeclareIdempotent(paths ...string) {
+	s.hedges.mu.Lock()
+	defer s.hedges.mu.Unlock()
+
+	if s.hedges.idempotent == nil {
+		s.hedges.idempotent = map[string]bool{}
+	}
+	for _, path := range paths {
+		s.hedges.idempotent[replaceOmitEmpty(path, "/", ".")] = true
+	}
+}
+
+// HedgePath sends a second request to the given destination path when the
+// first one did not reply after delay, e.g. the p95 latency of the path. The
+// first reply is used and the other request is cancelled. Both requests share
+// the x-trace-id and are numbered in the x-hedge-attempt meta. Paths not
+// declared with DeclareIdempotent are never hedged
+func (s *Service) HedgePath(path string, delay time.Duration) {
+	s.hedges.mu.Lock()
+	defer s.hedges.mu.Unlock()
+
+	if s.hedges.delays == nil {
+		s.hedges.delays = map[string]time.Duration{}
+	}
+	s.hedges.delays[replaceOmitEmpty(path, "/", ".")] = delay
+}
+
+func (s *Service) hedgeDelay(route string) (time.Duration, bool) {
+	s.hedges.mu.RLock()
+	defer s.hedges.mu.RUnlock()
+
+	delay, ok := s.hedges.delays[route]
+	return delay, ok && delay > 0 && s.hedges.idempotent[route]
+}
+
+// hedged wraps the invoker with the hedging of the route. A failed reply is
+// only used when the other request failed too
+func (s *Service) hedged(route string, next Invoker) Invoker {
+	delay, ok := s.hedgeDelay(route)
+	if !ok {
+		return next
+	}
+
+	return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		replies := make(chan interfaces.Response, 2)
+		send := func(attempt int) {
+			attemptReq := cloneRequest(req)
+			attemptReq.SetMetaProp("x-hedge-attempt", strconv.Itoa(attempt))
+
+			attemptRes := newResponse(res)
+			next(ctx, attemptReq, attemptRes)
+			replies <- attemptRes
+		}
+
+		go send(1)
+		pending := 1
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		var reply interfaces.Response
+		select {
+		case reply = <-replies:
+			pending--
+		case <-timer.C:
+			go send(2)
+			pending++
+		}
+
+		if reply == nil {
+			reply = <-replies
+			pending--
+		}
+
+		if reply.GetError() != nil && pending > 0 {
+			if other := <-replies; other.GetError() == nil {
+				reply = other
+			}
+		}
+
+		copyResponse(reply, res)
+	}
+}
+
+// copyResponse into a response of the same type
+func copyResponse(from, to interfaces.Response) {
+	src := reflect.ValueOf(from)
+	dst := reflect.ValueOf(to)
+	if src.Kind() == reflect.Ptr && dst.Kind() == reflect.Ptr && src.Type() == dst.Type() {
+		dst.Elem().Set(src.Elem())
+	}
+}
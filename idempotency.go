@@ -0,0 +1,197 @@
+package orion
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	oerror "github.com/gig/orion-go-sdk/error"
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/response"
+)
+
+const idempotencySweepInterval = time.Minute
+
+// IdempotencyStore keeps the encoded responses of the idempotent requests
+type IdempotencyStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, response []byte, ttl time.Duration)
+}
+
+type memoryEntry struct {
+	response []byte
+	expires  time.Time
+}
+
+// MemoryIdempotencyStore keeps the responses in memory, so it is not shared
+// between the instances of a service
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryIdempotencyStore for the responses of a single instance
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries:   map[string]memoryEntry{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Get the response stored for the key, unless it expired
+func (m *MemoryIdempotencyStore) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		return nil, false
+	}
+	return entry.response, true
+}
+
+// Set the response for the key. The expired entries are swept once in a while
+func (m *MemoryIdempotencyStore) Set(key string, response []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.entries[key] = memoryEntry{
+		response: response,
+		expires:  now.Add(ttl),
+	}
+
+	if now.Sub(m.lastSweep) > idempotencySweepInterval {
+		for k, entry := range m.entries {
+			if now.After(entry.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+}
+
+type idempotencySlotKey struct{}
+
+// idempotencySlot is shared between the idempotency middleware and the code
+// encoding the response of a request
+type idempotencySlot struct {
+	// replay is the stored response to send instead of the encoded one
+	replay []byte
+	// the fields below are set when the request runs the handler
+	key   string
+	call  *idempotentCall
+	store IdempotencyStore
+	ttl   time.Duration
+}
+
+type idempotentCall struct {
+	done chan struct{}
+}
+
+type idempotency struct {
+	mu       sync.Mutex
+	store    IdempotencyStore
+	inflight map[string]*idempotentCall
+}
+
+// begin the call for the key. It returns false when the same key is already
+// being handled, together with the call to wait for
+func (i *idempotency) begin(key string) (*idempotentCall, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if call, ok := i.inflight[key]; ok {
+		return call, false
+	}
+
+	if i.inflight == nil {
+		i.inflight = map[string]*idempotentCall{}
+	}
+
+	call := &idempotentCall{done: make(chan struct{})}
+	i.inflight[key] = call
+	return call, true
+}
+
+func (i *idempotency) end(key string, call *idempotentCall) {
+	i.mu.Lock()
+	delete(i.inflight, key)
+	i.mu.Unlock()
+
+	close(call.done)
+}
+
+// idempotent is the innermost middleware of the routes registered with
+// WithIdempotency. Requests with an idempotency-key meta already handled get
+// the stored response. Concurrent duplicates wait for the first one
+func (s *Service) idempotent(route string, ttl time.Duration, store IdempotencyStore) Middleware {
+	if store == nil {
+		store = s.idempotency.store
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req interfaces.Request) interfaces.Response {
+			slot, _ := ctx.Value(idempotencySlotKey{}).(*idempotencySlot)
+			key := req.GetMetaProp("idempotency-key")
+			if slot == nil || key == "" {
+				return next(ctx, req)
+			}
+			key = route + ":" + key
+
+			for {
+				if b, ok := store.Get(key); ok {
+					slot.replay = b
+					return response.New()
+				}
+
+				call, first := s.idempotency.begin(key)
+				if first {
+					slot.key = key
+					slot.call = call
+					slot.store = store
+					slot.ttl = ttl
+					return next(ctx, req)
+				}
+
+				select {
+				case <-call.done:
+					// the response was stored, unless it was an orion error
+				case <-ctx.Done():
+					if ctx.Err() == context.DeadlineExceeded {
+						return response.New().SetError(oerror.New("ORION_DEADLINE_EXCEEDED").SetMessage("timed out waiting for the request with the same idempotency key"))
+					}
+					return response.New().SetError(oerror.New("ORION_CANCELED").SetMessage("canceled waiting for the request with the same idempotency key"))
+				}
+			}
+		}
+	}
+}
+
+// withIdempotencySlot returns a context the idempotency middleware can use
+func withIdempotencySlot(ctx context.Context) (context.Context, *idempotencySlot) {
+	slot := &idempotencySlot{}
+	return context.WithValue(ctx, idempotencySlotKey{}, slot), slot
+}
+
+// completeIdempotent stores the encoded response of the request that ran the
+// handler and releases its concurrent duplicates. Orion errors, such as
+// ORION_INTERNAL, are not stored so the request can be retried
+func (s *Service) completeIdempotent(slot *idempotencySlot, res interfaces.Response, encoded []byte, encodeErr error) {
+	if slot == nil || slot.call == nil {
+		return
+	}
+
+	err := res.GetError()
+	if encodeErr == nil && (err == nil || !strings.HasPrefix(err.Code, "ORION_")) {
+		slot.store.Set(slot.key, encoded, slot.ttl)
+	}
+
+	s.idempotency.end(slot.key, slot.call)
+}
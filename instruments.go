@@ -0,0 +1,117 @@
+package orion
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/metrics"
+	"github.com/go-chi/chi"
+)
+
+const sharedPoolName = "shared"
+
+// instruments of the service, written on the /metrics endpoint
+type instruments struct {
+	registry        *metrics.Registry
+	requests        *metrics.CounterVec
+	requestErrors   *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	callDuration    *metrics.HistogramVec
+	callErrors      *metrics.CounterVec
+	emitted         *metrics.CounterVec
+	received        *metrics.CounterVec
+
+	mu    sync.Mutex
+	pools map[string]*workerPool
+}
+
+func newInstruments() *instruments {
+	r := metrics.New()
+
+	i := &instruments{
+		registry:        r,
+		requests:        r.Counter("orion_requests_total", "Requests per route, including the rejected ones.", "route"),
+		requestErrors:   r.Counter("orion_request_errors_total", "Requests replied with an error per route and code.", "route", "code"),
+		requestDuration: r.Histogram("orion_request_duration_seconds", "Latency of the handlers per route.", nil, "route"),
+		callDuration:    r.Histogram("orion_call_duration_seconds", "Latency of the outgoing calls per destination.", nil, "destination"),
+		callErrors:      r.Counter("orion_call_errors_total", "Outgoing calls failed per destination and code.", "destination", "code"),
+		emitted:         r.Counter("orion_emitted_total", "Messages emitted per topic.", "topic"),
+		received:        r.Counter("orion_received_total", "Messages received per subject.", "subject"),
+		pools:           map[string]*workerPool{},
+	}
+
+	r.Gauge("orion_pool_running", "Running workers per pool.", []string{"pool"}, i.collectPools(func(p *workerPool) int {
+		return p.pool.Running()
+	}))
+	r.Gauge("orion_pool_free", "Free workers per pool.", []string{"pool"}, i.collectPools(func(p *workerPool) int {
+		return p.pool.Free()
+	}))
+	r.Gauge("orion_pool_waiting", "Requests waiting for a free worker per pool.", []string{"pool"}, i.collectPools(func(p *workerPool) int {
+		return p.waiting()
+	}))
+
+	return i
+}
+
+func (i *instruments) addPool(name string, pool *workerPool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.pools[name] = pool
+}
+
+func (i *instruments) collectPools(value func(*workerPool) int) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		samples := make([]metrics.Sample, 0, len(i.pools))
+		for name, pool := range i.pools {
+			samples = append(samples, metrics.Sample{Values: []string{name}, Value: float64(value(pool))})
+		}
+		return samples
+	}
+}
+
+// observeRequest handled by the route
+func (i *instruments) observeRequest(route string, res interfaces.Response, took time.Duration) {
+	i.requests.Inc(route)
+	i.requestDuration.Observe(took.Seconds(), route)
+	if err := res.GetError(); err != nil {
+		i.requestErrors.Inc(route, err.Code)
+	}
+}
+
+// observeRejection of a request replied with an error without calling the
+// handler of the route
+func (i *instruments) observeRejection(route string, code string) {
+	i.requests.Inc(route)
+	i.requestErrors.Inc(route, code)
+}
+
+// observeCall to the destination route
+func (i *instruments) observeCall(destination string, res interfaces.Response, took time.Duration) {
+	i.callDuration.Observe(took.Seconds(), destination)
+	if err := res.GetError(); err != nil {
+		i.callErrors.Inc(destination, err.Code)
+	}
+}
+
+// Metrics registry of the service. Custom metrics registered on it are
+// exposed on the /metrics endpoint too
+func (s *Service) Metrics() *metrics.Registry {
+	return s.instruments.registry
+}
+
+// installMetrics exposes the metrics in the Prometheus text format on the
+// health HTTP server
+func (s *Service) installMetrics(router chi.Router) {
+	router.Get("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := s.instruments.registry.Write(w); err != nil {
+			s.logHTTPError(r, err)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package orion
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+)
+
+// Invoker performs an outgoing call. The last Invoker of the chain encodes the
+// request, sends it over the transport and decodes the reply into the response
+type Invoker func(ctx context.Context, req interfaces.Request, res interfaces.Response)
+
+// Interceptor wraps an Invoker. Code before next sees the request before it is
+// encoded and code after next sees the decoded response. Not calling next
+// skips the transport round trip altogether
+type Interceptor func(next Invoker) Invoker
+
+type interceptors struct {
+	mu      sync.RWMutex
+	service []Interceptor
+	paths   map[string][]Interceptor
+}
+
+// Intercept adds interceptors to every call made through Service.Call and
+// Service.CallStream
+func (s *Service) Intercept(ic ...Interceptor) {
+	s.interceptors.mu.Lock()
+	defer s.interceptors.mu.Unlock()
+
+	s.interceptors.service = append(s.interceptors.service, ic...)
+}
+
+// InterceptPath adds interceptors only for the calls to the given destination
+// path, e.g. "/calc/sum". They run after the ones added with Intercept
+func (s *Service) InterceptPath(path string, ic ...Interceptor) {
+	s.interceptors.mu.Lock()
+	defer s.interceptors.mu.Unlock()
+
+	if s.interceptors.paths == nil {
+		s.interceptors.paths = map[string][]Interceptor{}
+	}
+
+	route := replaceOmitEmpty(path, "/", ".")
+	s.interceptors.paths[route] = append(s.interceptors.paths[route], ic...)
+}
+
+// intercepted wraps the invoker with the service and the path interceptors
+func (s *Service) intercepted(path string, invoke Invoker) Invoker {
+	s.interceptors.mu.RLock()
+	defer s.interceptors.mu.RUnlock()
+
+	route := replaceOmitEmpty(path, "/", ".")
+	invoke = wrap(invoke, s.interceptors.paths[route])
+	return wrap(invoke, s.interceptors.service)
+}
+
+// wrap the invoker so the first interceptor is the outermost one
+func wrap(invoke Invoker, ic []Interceptor) Invoker {
+	for i := len(ic) - 1; i >= 0; i-- {
+		invoke = ic[i](invoke)
+	}
+	return invoke
+}
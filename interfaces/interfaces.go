@@ -1,6 +1,7 @@
 package interfaces
 
 import (
+	"context"
 	"time"
 
 	oerror "github.com/gig/orion-go-sdk/error"
@@ -26,6 +27,42 @@ type Transport interface {
 	OnClose(interface{})
 }
 
+// ContextRequester is implemented by the transports able to abort a request
+// once its context is done
+type ContextRequester interface {
+	RequestWithContext(context.Context, string, []byte) ([]byte, error)
+}
+
+// MultiRequester is implemented by the transports able to receive many
+// replies to a single request
+type MultiRequester interface {
+	RequestMany(context.Context, string, []byte, func([]byte) bool) error
+}
+
+// Unsubscriber is implemented by the transports able to remove subscriptions
+type Unsubscriber interface {
+	Unsubscribe(string) error
+}
+
+// SingleSubscriber is implemented by the transports able to remove a single
+// subscription, leaving the others to the same subject in place. The returned
+// func removes the subscription
+type SingleSubscriber interface {
+	SubscribeSingle(string, string, func([]byte)) (func() error, error)
+}
+
+// Drainer is implemented by the transports able to stop the delivery of new
+// requests while the ones already delivered are processed and replied. Drain
+// returns how many requests were dropped instead of being delivered
+type Drainer interface {
+	Drain() (int, error)
+}
+
+// Flusher is implemented by the transports buffering outgoing messages
+type Flusher interface {
+	Flush() error
+}
+
 // Response interface
 type Response interface {
 	GetError() *oerror.Error
@@ -34,6 +71,15 @@ type Response interface {
 	SetPayload(interface{}) error
 }
 
+// MetaResponse is implemented by the responses carrying meta, e.g. the
+// cache-control set by the callee
+type MetaResponse interface {
+	Response
+	GetMeta() map[string]string
+	GetMetaProp(key string) string
+	SetMetaProp(key, value string) Response
+}
+
 // Request interface
 type Request interface {
 	GetPath() string
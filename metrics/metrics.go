@@ -0,0 +1,228 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets for latencies in seconds
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+)
+
+// Sample of a gauge collected when the metrics are written
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+// Registry of metrics written in the Prometheus text format
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// New registry
+func New() *Registry {
+	return &Registry{}
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	collect func() []Sample
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	// counts per bucket, only for histograms
+	counts []uint64
+	count  uint64
+}
+
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f.series = map[string]*series{}
+	r.families = append(r.families, f)
+	return f
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	family *family
+}
+
+// Counter registers a counter with the given labels
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{r.register(&family{name: name, help: help, kind: counterType, labels: labels})}
+}
+
+// Inc the counter for the label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add to the counter for the label values
+func (c *CounterVec) Add(v float64, values ...string) {
+	c.family.mu.Lock()
+	defer c.family.mu.Unlock()
+
+	c.family.get(values).value += v
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	family *family
+}
+
+// Histogram registers a histogram with the given buckets and labels. Nil
+// buckets means DefaultBuckets
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &HistogramVec{r.register(&family{name: name, help: help, kind: histogramType, labels: labels, buckets: buckets})}
+}
+
+// Observe a value for the label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.family.mu.Lock()
+	defer h.family.mu.Unlock()
+
+	s := h.family.get(values)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.family.buckets))
+	}
+	for i, bound := range h.family.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// Gauge registers a gauge whose samples are collected when the metrics are
+// written
+func (r *Registry) Gauge(name, help string, labels []string, collect func() []Sample) {
+	r.register(&family{name: name, help: help, kind: gaugeType, labels: labels, collect: collect})
+}
+
+func (f *family) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Write the metrics in the Prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family{}, r.families...)
+	r.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(buf)
+	}
+	return buf.Flush()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	w.WriteString("# HELP " + f.name + " " + helpEscaper.Replace(f.help) + "\n")
+	w.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+
+	if f.collect != nil {
+		for _, sample := range f.collect() {
+			w.WriteString(f.name + labels(f.labels, sample.Values, "", "") + " " + formatFloat(sample.Value) + "\n")
+		}
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+
+		if f.kind != histogramType {
+			w.WriteString(f.name + labels(f.labels, s.values, "", "") + " " + formatFloat(s.value) + "\n")
+			continue
+		}
+
+		for i, bound := range f.buckets {
+			w.WriteString(f.name + "_bucket" + labels(f.labels, s.values, "le", formatFloat(bound)) + " " + strconv.FormatUint(s.counts[i], 10) + "\n")
+		}
+		w.WriteString(f.name + "_bucket" + labels(f.labels, s.values, "le", "+Inf") + " " + strconv.FormatUint(s.count, 10) + "\n")
+		w.WriteString(f.name + "_sum" + labels(f.labels, s.values, "", "") + " " + formatFloat(s.value) + "\n")
+		w.WriteString(f.name + "_count" + labels(f.labels, s.values, "", "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// labels formats the label pairs, plus an extra one when extra is not empty
+func labels(names, values []string, extra, extraValue string) string {
+	pairs := []string{}
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name+`="`+escape(value)+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra+`="`+extraValue+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	escaper     = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+// escape a label value, as required by the Prometheus text format
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	r := New()
+
+	requests := r.Counter("requests_total", "Requests handled.", "route")
+	requests.Inc("calc.add")
+	requests.Inc("calc.add")
+
+	latency := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.Observe(0.5, "calc.add")
+
+	r.Gauge("running", "Running workers.", []string{"pool"}, func() []Sample {
+		return []Sample{{Values: []string{"shared"}, Value: 3}}
+	})
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+
+	assert.Equal(t, `# HELP requests_total Requests handled.
+# TYPE requests_total counter
+requests_total{route="calc.add"} 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="calc.add",le="0.1"} 0
+latency_seconds_bucket{route="calc.add",le="1"} 1
+latency_seconds_bucket{route="calc.add",le="+Inf"} 1
+latency_seconds_sum{route="calc.add"} 0.5
+latency_seconds_count{route="calc.add"} 1
+# HELP running Running workers.
+# TYPE running gauge
+running{pool="shared"} 3
+`, buf.String())
+}
+
+func TestEscape(t *testing.T) {
+	r := New()
+
+	errors := r.Counter("errors_total", "Errors per path\nand \\ code.", "path")
+	errors.Inc("a\\b\"c\nd")
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+
+	assert.Equal(t, `# HELP errors_total Errors per path\nand \\ code.
+# TYPE errors_total counter
+errors_total{path="a\\b\"c\nd"} 1
+`, buf.String())
+}
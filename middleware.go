@@ -0,0 +1,61 @@
+package orion
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+)
+
+// HandlerFunc is the shape every handler is reduced to once registered. It
+// receives the decoded request and returns the response sent to the caller
+type HandlerFunc func(ctx context.Context, req interfaces.Request) interfaces.Response
+
+// Middleware wraps a HandlerFunc. It can inspect the request before calling
+// next and the response after. To short-circuit the chain, return a response
+// without calling next, usually one carrying an oerror.Error:
+//
+//	func auth(next orion.HandlerFunc) orion.HandlerFunc {
+//		return func(ctx context.Context, req interfaces.Request) interfaces.Response {
+//			if req.GetMetaProp("token") == "" {
+//				return response.New().SetError(orion.ServiceError("UNAUTHORIZED"))
+//			}
+//			return next(ctx, req)
+//		}
+//	}
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Use adds middleware to every handler registered after the call. Service
+// middleware runs in the order it was added and before the route middleware
+// passed with WithMiddleware
+func (s *Service) Use(mw ...Middleware) {
+	s.middleware = append(s.middleware, mw...)
+}
+
+// chain wraps the handler so the first middleware is the outermost one
+func chain(handler HandlerFunc, mw []Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
+
+// reflectHandler adapts a handler of shape func(*CustomReq) *CustomRes or
+// func(context.Context, *CustomReq) *CustomRes
+func reflectHandler(method reflect.Value) HandlerFunc {
+	withContext := method.Type().NumIn() == 2
+
+	return func(ctx context.Context, req interfaces.Request) interfaces.Response {
+		args := []reflect.Value{reflect.ValueOf(req)}
+		if withContext {
+			args = []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(req)}
+		}
+
+		raw := method.Call(args)[0].Interface()
+
+		res, ok := raw.(interfaces.Response)
+		checkResponseCast(ok)
+
+		return res
+	}
+}
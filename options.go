@@ -1,6 +1,11 @@
 package orion
 
-import "github.com/gig/orion-go-sdk/interfaces"
+import (
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/tracing"
+)
 
 // client-service
 
@@ -11,6 +16,11 @@ type Options struct {
 	Logger              interfaces.Logger
 	DisableHealthChecks bool
 	HTTPPort            int
+	DrainTimeout        time.Duration
+	Version             string
+	AnnounceInterval    time.Duration
+	TraceExporter       tracing.Exporter
+	MaxPropagation      int
 }
 
 // Option type
@@ -23,9 +33,168 @@ func SetCodec(codec interfaces.Codec) Option {
 	}
 }
 
+// SetDrainTimeout for orion. When set, closing the service waits up to the
+// timeout for the requests in flight to be replied
+func SetDrainTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.DrainTimeout = timeout
+	}
+}
+
+// SetVersion for orion. It is announced together with the routes
+func SetVersion(version string) Option {
+	return func(o *Options) {
+		o.Version = version
+	}
+}
+
+// SetAnnounceInterval for orion. The service announces itself on the registry
+// subject every interval while it listens. A negative interval disables it.
+// Defaults to the ORION_ANNOUNCE_INTERVAL env var in milliseconds, or 5s. An
+// env var <= 0 disables it too
+func SetAnnounceInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.AnnounceInterval = interval
+	}
+}
+
+// SetTraceExporter for orion. The server spans of the handlers and the client
+// spans of the calls and emits are exported with it. Without an exporter the
+// spans are only propagated in the traceparent meta
+func SetTraceExporter(exporter tracing.Exporter) Option {
+	return func(o *Options) {
+		o.TraceExporter = exporter
+	}
+}
+
+// SetMaxPropagation for orion. Calls and requests that went through more
+// hops, counted by request.Merge, fail with an ORION_PROPAGATION_LIMIT error.
+// Defaults to the ORION_MAX_PROPAGATION env var. 0 means no limit
+func SetMaxPropagation(max int) Option {
+	return func(o *Options) {
+		o.MaxPropagation = max
+	}
+}
+
 // SetTransport for orion
 func SetTransport(transport interfaces.Transport) Option {
 	return func(o *Options) {
 		o.Transport = transport
 	}
 }
+
+// calls
+
+// CallOptions for a single call
+type CallOptions struct {
+	Instance string
+}
+
+// CallOption type
+type CallOption func(*CallOptions)
+
+func newCallOptions(options []CallOption) *CallOptions {
+	opts := &CallOptions{}
+	for _, setter := range options {
+		setter(opts)
+	}
+	return opts
+}
+
+// ToInstance sends the call to the instance with the given ID instead of any
+// instance of the destination service. The responses of those calls are never
+// cached nor coalesced
+func ToInstance(id string) CallOption {
+	return func(o *CallOptions) {
+		o.Instance = id
+	}
+}
+
+// server-side routes
+
+// HandleOptions for a single route
+type HandleOptions struct {
+	Middleware []Middleware
+	PoolSize   int
+	QueueSize  int
+	MaxWait    time.Duration
+
+	IdempotencyTTL   time.Duration
+	IdempotencyStore IdempotencyStore
+
+	Version        string
+	DefaultVersion bool
+}
+
+// HandleOption type
+type HandleOption func(*HandleOptions)
+
+func newHandleOptions(options []HandleOption) *HandleOptions {
+	opts := &HandleOptions{}
+	for _, setter := range options {
+		setter(opts)
+	}
+	return opts
+}
+
+// WithMiddleware adds middleware only for the route being registered. It runs
+// after the middleware added with Service.Use
+func WithMiddleware(mw ...Middleware) HandleOption {
+	return func(o *HandleOptions) {
+		o.Middleware = append(o.Middleware, mw...)
+	}
+}
+
+// WithPool runs the route on a dedicated pool of size workers instead of the
+// shared one. Up to queue requests can wait for a free worker, the rest are
+// rejected with an ORION_OVERLOADED error. With a queue of 0 the requests are
+// rejected as soon as every worker is busy
+func WithPool(size, queue int) HandleOption {
+	return func(o *HandleOptions) {
+		o.PoolSize = size
+		o.QueueSize = queue
+	}
+}
+
+// WithMaxWait rejects the requests of the route that waited longer than the
+// given duration for a free worker with an ORION_OVERLOADED error. It
+// overrides the THREADPOOL_MAX_WAIT of the service
+func WithMaxWait(maxWait time.Duration) HandleOption {
+	return func(o *HandleOptions) {
+		o.MaxWait = maxWait
+	}
+}
+
+// WithIdempotency replays the stored response to the requests with an
+// idempotency-key meta that was already handled by the route in the last ttl.
+// Duplicates arriving while the first request runs wait for its response
+func WithIdempotency(ttl time.Duration) HandleOption {
+	return func(o *HandleOptions) {
+		o.IdempotencyTTL = ttl
+	}
+}
+
+// WithIdempotencyStore keeps the responses of WithIdempotency in the given
+// store instead of the memory of the instance
+func WithIdempotencyStore(store IdempotencyStore) HandleOption {
+	return func(o *HandleOptions) {
+		o.IdempotencyStore = store
+	}
+}
+
+// WithVersion routes the handler with the version after the service name, e.g.
+// the add handler of calc with version v2 is called with /calc/v2/add. Other
+// versions of the same path can be served side by side
+func WithVersion(version string) HandleOption {
+	return func(o *HandleOptions) {
+		o.Version = version
+	}
+}
+
+// AsDefaultVersion also routes the versioned handler without its version, so
+// /calc/add calls it too
+func AsDefaultVersion() HandleOption {
+	return func(o *HandleOptions) {
+		o.DefaultVersion = true
+	}
+}
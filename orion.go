@@ -1,14 +1,18 @@
 package orion
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"reflect"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gig/orion-go-sdk/codec/msgpack"
 	"github.com/gig/orion-go-sdk/env"
@@ -17,16 +21,27 @@ import (
 	"github.com/gig/orion-go-sdk/health/checks"
 	"github.com/gig/orion-go-sdk/interfaces"
 	"github.com/gig/orion-go-sdk/logger"
+	"github.com/gig/orion-go-sdk/registry"
+	"github.com/gig/orion-go-sdk/request"
 	"github.com/gig/orion-go-sdk/response"
+	"github.com/gig/orion-go-sdk/tracing"
 	"github.com/gig/orion-go-sdk/transport/nats"
 	"github.com/panjf2000/ants"
 	uuid "github.com/satori/go.uuid"
 )
 
-const defaultThreadPoolSize = 1
+const (
+	defaultThreadPoolSize      = 1
+	defaultThreadPoolQueueSize = 1024
+	drainPollInterval          = 10 * time.Millisecond
+)
 
 var (
 	threadPoolSize = env.Get("THREADPOOL_SIZE", strconv.Itoa(defaultThreadPoolSize))
+	// requests that can wait for a free worker, the rest are rejected
+	threadPoolQueueSize = env.Get("THREADPOOL_QUEUE_SIZE", strconv.Itoa(defaultThreadPoolQueueSize))
+	// milliseconds a request can wait for a free worker. 0 means no limit
+	threadPoolMaxWait = env.Get("THREADPOOL_MAX_WAIT", "0")
 )
 
 // Factory func type - the one that creates the req obj
@@ -34,18 +49,42 @@ type Factory = func() interfaces.Request
 
 // Service for orion
 type Service struct {
+	// pending requests, accessed atomically. Kept first for 64-bit alignment
+	pending             int64
 	ID                  string
 	Name                string
+	Version             string
 	Timeout             int
 	Codec               interfaces.Codec
 	Transport           interfaces.Transport
 	Logger              interfaces.Logger
+	Tracer              *tracing.Tracer
 	ThreadPool          *ants.PoolWithFunc
 	HealthChecks        []health.Dependency
 	StopHealthCheck     chan struct{}
 	HTTPServer          *http.Server
 	HTTPPort            int
 	DisableHealthChecks bool
+	DrainTimeout        time.Duration
+	MaxWait             time.Duration
+	AnnounceInterval    time.Duration
+	MaxPropagation      int
+	middleware          []Middleware
+	interceptors        *interceptors
+	retries             *retries
+	breakers            *breakers
+	sharedPool          *workerPool
+	rejections          *rejections
+	idempotency         *idempotency
+	cache               *responseCache
+	coalescing          *coalescing
+	hedges              *hedges
+	routes              *routes
+	instruments         *instruments
+	routePools          []*workerPool
+	stopAnnouncing      func()
+	ctx                 context.Context
+	cancel              context.CancelFunc
 }
 
 // DefaultServiceOptions setup
@@ -58,6 +97,30 @@ func DefaultServiceOptions(opt *Options) {
 		opt.HTTPPort = thePort
 	}
 	opt.DisableHealthChecks = env.Truthy("DISABLE_HEALTH_CHECK")
+
+	if opt.DrainTimeout == 0 {
+		drainTimeout, err := strconv.Atoi(env.Get("DRAIN_TIMEOUT", "0"))
+		if err != nil {
+			panic(err)
+		}
+		opt.DrainTimeout = time.Duration(drainTimeout) * time.Millisecond
+	}
+
+	if opt.AnnounceInterval == 0 {
+		announceInterval, err := strconv.Atoi(env.Get("ORION_ANNOUNCE_INTERVAL", strconv.Itoa(int(registry.DefaultInterval/time.Millisecond))))
+		if err != nil {
+			panic(err)
+		}
+		opt.AnnounceInterval = time.Duration(announceInterval) * time.Millisecond
+	}
+
+	if opt.MaxPropagation == 0 {
+		maxPropagation, err := strconv.Atoi(env.Get("ORION_MAX_PROPAGATION", "0"))
+		if err != nil {
+			panic(err)
+		}
+		opt.MaxPropagation = maxPropagation
+	}
 }
 
 // UniqueName for given name and unique id
@@ -94,28 +157,60 @@ func New(name string, options ...Option) *Service {
 		poolSize = defaultThreadPoolSize
 	}
 
-	workerPool, err := ants.NewPoolWithFunc(poolSize, func(fn interface{}) {
-		toCall := fn.(func())
-		toCall()
-	}, ants.WithNonblocking(false))
+	queueSize, err := strconv.Atoi(threadPoolQueueSize)
+
+	if err != nil {
+		queueSize = defaultThreadPoolQueueSize
+	}
+
+	maxWait, err := strconv.Atoi(threadPoolMaxWait)
+
+	if err != nil {
+		maxWait = 0
+	}
+
+	workerPool, err := newWorkerPool(poolSize, queueSize)
 
 	if err != nil {
 		panic(err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	s := &Service{
 		ID:                  uid.String(),
 		Name:                name,
+		Version:             opts.Version,
 		Timeout:             200,
 		Codec:               opts.Codec,
 		Transport:           opts.Transport,
 		Logger:              opts.Logger,
-		ThreadPool:          workerPool,
+		Tracer:              tracing.NewTracer(name, opts.TraceExporter),
+		ThreadPool:          workerPool.pool,
 		HealthChecks:        make([]health.Dependency, 0),
 		HTTPPort:            opts.HTTPPort,
 		DisableHealthChecks: opts.DisableHealthChecks,
+		DrainTimeout:        opts.DrainTimeout,
+		MaxWait:             time.Duration(maxWait) * time.Millisecond,
+		AnnounceInterval:    opts.AnnounceInterval,
+		MaxPropagation:      opts.MaxPropagation,
+		interceptors:        &interceptors{},
+		retries:             &retries{},
+		breakers:            &breakers{},
+		sharedPool:          workerPool,
+		rejections:          &rejections{},
+		idempotency:         &idempotency{store: NewMemoryIdempotencyStore()},
+		cache:               &responseCache{},
+		coalescing:          &coalescing{},
+		hedges:              &hedges{},
+		routes:              &routes{},
+		instruments:         newInstruments(),
+		ctx:                 ctx,
+		cancel:              cancel,
 	}
 
+	s.instruments.addPool(sharedPoolName, workerPool)
+
 	if !opts.DisableHealthChecks {
 		s.RegisterHealthCheck(checks.NatsHealthcheck(opts.Transport))
 	}
@@ -125,24 +220,64 @@ func New(name string, options ...Option) *Service {
 
 // Emit to services
 func (s *Service) Emit(topic string, data interface{}) error {
+	return s.EmitContext(context.Background(), topic, data)
+}
+
+// EmitContext works the same as Emit but the producer span is a child of the
+// span carried by the context, e.g. the one of the handler emitting. When the
+// data is a request, its traceparent meta is used otherwise and the request
+// carries the producer span to the subscribers
+func (s *Service) EmitContext(ctx context.Context, topic string, data interface{}) error {
+	req, _ := data.(interfaces.Request)
+
+	traceID := TraceID(ctx)
+	if req != nil && req.GetID() != "" {
+		traceID = req.GetID()
+	}
+
+	span := s.Tracer.Start(topic, tracing.Producer, parentSpan(ctx, req), traceID)
+	span.SetAttribute("orion.topic", topic)
+	defer span.Finish()
+
+	if req != nil {
+		defer setCallMeta(req, tracing.TraceparentKey, span.Context().Traceparent())()
+	}
+
 	msg, err := s.Codec.Encode(data)
 	if err != nil {
+		span.SetError("ORION_ENCODE", err.Error())
+		return err
+	}
+
+	err = s.Transport.Publish(topic, msg)
+	if err != nil {
+		span.SetError("ORION_TRANSPORT", err.Error())
 		return err
 	}
-	return s.Transport.Publish(topic, msg)
+
+	s.instruments.emitted.Inc(topic)
+	return nil
 }
 
 // On service emit
 func (s *Service) On(topic string, handler func([]byte)) {
 	subject := fmt.Sprintf("%s:%s", s.Name, topic)
-	s.Transport.Subscribe(subject, s.Name, handler)
+	s.routes.addEvent(EventInfo{Subject: subject})
+	s.Transport.Subscribe(subject, s.Name, func(data []byte) {
+		s.instruments.received.Inc(subject)
+		handler(data)
+	})
 }
 
 // SubscribeForRawMsg is like service.On except that it receives the raw messages
 // specific for the transport protocol instead of the message payload
 func (s *Service) SubscribeForRawMsg(topic string, handler func(interface{})) {
 	subject := fmt.Sprintf("%s:%s", s.Name, topic)
-	s.Transport.SubscribeForRawMsg(subject, s.Name, handler)
+	s.routes.addEvent(EventInfo{Subject: subject, Raw: true})
+	s.Transport.SubscribeForRawMsg(subject, s.Name, func(msg interface{}) {
+		s.instruments.received.Inc(subject)
+		handler(msg)
+	})
 }
 
 // Decode bytes to passed interface
@@ -151,56 +286,254 @@ func (s *Service) Decode(data []byte, to interface{}) error {
 }
 
 // HandleWithoutLogging works the same as Handle but with disabled logging
-func (s *Service) HandleWithoutLogging(path string, handler interface{}, factory Factory) {
-	s.handle(path, logger.NONE, handler, factory)
+func (s *Service) HandleWithoutLogging(path string, handler interface{}, factory Factory, options ...HandleOption) {
+	s.handle(path, logger.NONE, handler, factory, options)
 }
 
 // HandleWithCustomLogLevel works the same as Handle but it lets you set the log level
-func (s *Service) HandleWithCustomLogLevel(path string, logLevel int, handler interface{}, factory Factory) {
-	s.handle(path, logLevel, handler, factory)
+func (s *Service) HandleWithCustomLogLevel(path string, logLevel int, handler interface{}, factory Factory, options ...HandleOption) {
+	s.handle(path, logLevel, handler, factory, options)
 }
 
 // Handle has enabled logging. What that means is when the request
 // arrives the service will log the request including the raw params. Once the
 // response is returned, the service will check for error and if there is such,
 // the error will be logged
-func (s *Service) Handle(path string, handler interface{}, factory Factory) {
-	s.handle(path, logger.INFO, handler, factory)
+func (s *Service) Handle(path string, handler interface{}, factory Factory, options ...HandleOption) {
+	s.handle(path, logger.INFO, handler, factory, options)
 }
 
-func (s *Service) handle(path string, logLevel int, handler interface{}, factory Factory) {
-	route := s.getRouteFromPath(path)
-
+func (s *Service) handle(path string, logLevel int, handler interface{}, factory Factory, options []HandleOption) {
 	method := reflect.ValueOf(handler)
 	s.checkHandler(method)
 
-	s.Transport.Handle(route, s.Name, func(data []byte, reply func([]byte)) {
-		toProcess := func() {
-			req := factory()
+	s.register(path, logLevel, reflectHandler(method), factory, method.Type().Out(0), options)
+}
 
-			err := s.Codec.Decode(data, req)
-			req.SetError(err)
+// register subscribes the handler for the route computed from the path. The
+// service and route middleware are resolved once, at registration time
+func (s *Service) register(path string, logLevel int, handler HandlerFunc, factory Factory, res reflect.Type, options []HandleOption) {
+	opts := newHandleOptions(options)
+	route, aliases := s.routesFor(path, opts)
+	s.routes.add(s.routeInfo(route, opts, logLevel, factory, res))
+
+	mw := make([]Middleware, 0, len(s.middleware)+len(opts.Middleware))
+	mw = append(mw, s.middleware...)
+	mw = append(mw, opts.Middleware...)
+	if opts.IdempotencyTTL > 0 {
+		mw = append(mw, s.idempotent(route, opts.IdempotencyTTL, opts.IdempotencyStore))
+	}
+	handler = chain(handler, mw)
+
+	s.subscribe(route, aliases, opts, func(data []byte, reply func([]byte), received time.Time) {
+		req, ok := s.decodeRequest(data, factory, logLevel, reply)
+		if !ok {
+			return
+		}
 
-			s.logRequest(err, req, logLevel)
+		ctx, finish, ok := s.begin(route, req, received, reply, s.handlerContext)
+		if !ok {
+			return
+		}
+
+		ctx, slot := withIdempotencySlot(ctx)
+		res := s.serve(ctx, handler, req, logLevel)
+		finish(res)
 
-			if err != nil {
-				panic(err)
+		if slot.replay != nil {
+			reply(slot.replay)
+			return
+		}
+
+		b, err := s.Codec.Encode(res)
+		if err != nil {
+			encodeErr := oerror.New("ORION_ENCODE").SetMessage(err.Error())
+
+			s.Logger.
+				CreateMessage("ORION_ENCODE " + req.GetPath()).
+				SetLevel(logger.ERROR).
+				SetID(req.GetID()).
+				SetParams(encodeErr).
+				Send()
+
+			b = s.encodeError(encodeErr)
+		}
+
+		s.completeIdempotent(slot, res, b, err)
+		reply(b)
+	})
+}
+
+// begin serving the request delivered to the route. When its deadline is over
+// or it went through too many hops, the error is replied and ok is false.
+// Otherwise the context made by newContext carries the server span, and finish
+// must be called with the response to record it
+func (s *Service) begin(route string, req interfaces.Request, received time.Time, reply func([]byte),
+	newContext func(interfaces.Request, time.Time) (context.Context, context.CancelFunc)) (ctx context.Context, finish func(interfaces.Response), ok bool) {
+	if deadline, ok := request.Deadline(req); ok && time.Now().After(deadline) {
+		s.expired(route, req, deadline, reply)
+		return nil, nil, false
+	}
+
+	if err := s.propagationLimit(req); err != nil {
+		s.rejected(route, req, err, reply)
+		return nil, nil, false
+	}
+	s.detectCycle(req, s.Name)
+
+	span := s.startServerSpan(route, req, received)
+	ctx, cancel := newContext(req, received)
+	start := time.Now()
+
+	return tracing.ContextWithSpan(ctx, span), func(res interfaces.Response) {
+		s.instruments.observeRequest(route, res, time.Since(start))
+		finishSpan(span, res)
+		cancel()
+	}, true
+}
+
+// expired replies ORION_DEADLINE_EXCEEDED without calling the handler, since
+// the caller stopped waiting for the reply
+func (s *Service) expired(route string, req interfaces.Request, deadline time.Time, reply func([]byte)) {
+	err := oerror.New("ORION_DEADLINE_EXCEEDED").SetMessage("the deadline of the request was over " + time.Since(deadline).String() + " ago")
+	s.rejected(route, req, err, reply)
+}
+
+// rejected replies the error without calling the handler
+func (s *Service) rejected(route string, req interfaces.Request, err *oerror.Error, reply func([]byte)) {
+	s.instruments.observeRejection(route, err.Code)
+
+	s.Logger.
+		CreateMessage(err.Code + " " + req.GetPath()).
+		SetLevel(logger.WARNING).
+		SetID(req.GetID()).
+		SetParams(err).
+		Send()
+
+	reply(s.encodeError(err))
+}
+
+// processor handles a message delivered to a route. It runs on a worker of
+// the route pool and replies through the reply func
+type processor func(data []byte, reply func([]byte), received time.Time)
+
+// subscribe the route on the transport. Every message is processed on the
+// pool of the route, unless it is rejected because the pool is overloaded
+func (s *Service) subscribe(route string, aliases []string, opts *HandleOptions, process processor) {
+	pool := s.sharedPool
+	if opts.PoolSize > 0 {
+		var err error
+		pool, err = newWorkerPool(opts.PoolSize, opts.QueueSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.routePools = append(s.routePools, pool)
+		s.instruments.addPool(route, pool)
+	}
+
+	maxWait := s.MaxWait
+	if opts.MaxWait > 0 {
+		maxWait = opts.MaxWait
+	}
+
+	overloaded := func(reply func([]byte), reason string) {
+		s.rejections.add(route)
+		s.instruments.observeRejection(route, "ORION_OVERLOADED")
+		reply(s.encodeError(oerror.New("ORION_OVERLOADED").SetMessage(reason)))
+	}
+
+	handler := func(data []byte, reply func([]byte)) {
+		// submit never blocks the transport callback, so the messages do not
+		// wait in the transport and the max wait counts from their arrival
+		received := time.Now()
+		atomic.AddInt64(&s.pending, 1)
+
+		toProcess := func() {
+			defer atomic.AddInt64(&s.pending, -1)
+
+			if waited := time.Since(received); maxWait > 0 && waited > maxWait {
+				overloaded(reply, "the request waited "+waited.String()+" for a free worker")
+				return
 			}
 
-			res := method.Call([]reflect.Value{reflect.ValueOf(req)})[0].Interface()
+			process(data, reply, received)
+		}
 
-			s.logResponse(req, res, logLevel)
+		pool.submit(toProcess, func(err error) {
+			atomic.AddInt64(&s.pending, -1)
 
-			b, err := s.Codec.Encode(res)
-			if err != nil {
-				log.Fatal(err)
+			if err == errOverloaded {
+				overloaded(reply, err.Error())
 			}
+		})
+	}
+
+	for _, subject := range append([]string{route}, aliases...) {
+		s.Transport.Handle(subject, s.Name, handler)
+		s.Transport.Handle(instanceRoute(subject, s.ID), s.Name, handler)
+		s.handleBroadcast(subject, handler)
+	}
+}
+
+// decodeRequest created by the factory and log it. When the data cannot be
+// decoded, the caller is replied with an ORION_DECODE error
+func (s *Service) decodeRequest(data []byte, factory Factory, logLevel int, reply func([]byte)) (interfaces.Request, bool) {
+	req := factory()
+
+	err := s.Codec.Decode(data, req)
+	req.SetError(err)
+
+	s.logRequest(err, req, logLevel)
+
+	if err != nil {
+		reply(s.encodeError(oerror.New("ORION_DECODE").SetMessage(err.Error())))
+		return nil, false
+	}
+
+	return req, true
+}
+
+// serve calls the handler and logs the response. A panic is recovered into an
+// ORION_INTERNAL error response, so a single request cannot stop the service
+func (s *Service) serve(ctx context.Context, handler HandlerFunc, req interfaces.Request, logLevel int) (res interfaces.Response) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := oerror.New("ORION_INTERNAL").SetMessage(fmt.Sprint(r))
 
-			reply(b)
+			s.Logger.
+				CreateMessage("ORION_INTERNAL " + req.GetPath()).
+				SetLevel(logger.ERROR).
+				SetID(req.GetID()).
+				SetMap(map[string]interface{}{
+					"error": err,
+					"stack": string(debug.Stack()),
+				}).
+				Send()
+
+			res = response.New().SetError(err)
 		}
+	}()
 
-		s.ThreadPool.Invoke(toProcess)
-	})
+	res = handler(ctx, req)
+
+	s.logResponse(req, res, logLevel)
+
+	return res
+}
+
+// encodeError into a response that every caller is able to decode
+func (s *Service) encodeError(err *oerror.Error) []byte {
+	b, _ := s.Codec.Encode(response.New().SetError(err))
+	return b
+}
+
+// logHTTPError that happened replying a request to the health HTTP server
+func (s *Service) logHTTPError(r *http.Request, err error) {
+	s.Logger.
+		CreateMessage("http reply failed " + r.URL.Path).
+		SetLevel(logger.WARNING).
+		SetParams(err.Error()).
+		Send()
 }
 
 func (s *Service) RegisterHealthCheck(check *health.Dependency) {
@@ -217,39 +550,132 @@ func (s *Service) RegisterHealthCheck(check *health.Dependency) {
 }
 
 // Call orion service
-func (s *Service) Call(req interfaces.Request, raw interface{}) {
+func (s *Service) Call(req interfaces.Request, raw interface{}, options ...CallOption) {
+	s.call(context.Background(), req, raw, oerror.GenerateLOC(1), options...)
+}
+
+// CallContext works the same as Call but the call is aborted once the context
+// is done. When the context has a deadline, the call will not wait longer than
+// it, even if the request timeout is bigger. When the request does not have an
+// ID, the trace ID carried by the context is used
+func (s *Service) CallContext(ctx context.Context, req interfaces.Request, raw interface{}, options ...CallOption) {
+	s.call(ctx, req, raw, oerror.GenerateLOC(1), options...)
+}
+
+func (s *Service) call(ctx context.Context, req interfaces.Request, raw interface{}, loc oerror.LineOfCode, options ...CallOption) {
 	res, ok := raw.(interfaces.Response)
 	checkResponseCast(ok)
 
-	encoded, err := s.Codec.Encode(req)
-	if err != nil {
-		res.SetError(oerror.New("ORION_ENCODE").SetMessage(err.Error()).SetLineOfCode(oerror.GenerateLOC(1)))
-		return
+	opts := newCallOptions(options)
+	if opts.Instance != "" {
+		ctx = context.WithValue(ctx, instanceKey{}, opts.Instance)
 	}
 
-	path := replaceOmitEmpty(req.GetPath(), "/", ".")
-	b, err := s.Transport.Request(path, encoded, s.getTimeout(req))
-	if err != nil {
-		res.SetError(oerror.New("ORION_TRANSPORT").SetMessage(err.Error()).SetLineOfCode(oerror.GenerateLOC(1)))
-		return
+	route := replaceOmitEmpty(req.GetPath(), "/", ".")
+
+	invoke := s.invoker(loc)
+	invoke = s.hedged(route, invoke)
+	invoke = s.breaking(route, loc, invoke)
+	invoke = s.retrying(route, invoke)
+	// responses of a given instance are not shared with the other calls
+	if opts.Instance == "" {
+		invoke = s.coalesced(route, invoke)
+		invoke = s.caching(route, invoke)
 	}
+	invoke = s.intercepted(route, invoke)
 
-	err = s.Codec.Decode(b, res)
-	if err != nil {
-		res.SetError(oerror.New("ORION_DECODE").SetMessage(err.Error()).SetLineOfCode(oerror.GenerateLOC(1)))
+	s.send(ctx, route, req, res, loc, invoke)
+}
 
-		s.Logger.
-			CreateMessage("ORION_DECODE " + req.GetPath()).
-			SetLevel(logger.ERROR).
-			SetID(req.GetID()).
-			SetMap(map[string]interface{}{
-				"error": res.GetError(),
-			}).
-			SetLineOfCode(oerror.GenerateLOC(1)).
-			Send()
+// send the request to the route through the invoker chain of a call. The
+// call is traced and measured, and the request carries the trace ID of the
+// context, the deadline and the call chain
+func (s *Service) send(ctx context.Context, route string, req interfaces.Request, res interfaces.Response, loc oerror.LineOfCode, invoke Invoker) {
+	s.sendUntil(ctx, route, req, res, loc, time.Now().Add(s.callTimeout(ctx, req)), invoke)
+}
+
+// sendUntil works the same as send with the given deadline. A zero deadline
+// only keeps the one inherited by the request, if any
+func (s *Service) sendUntil(ctx context.Context, route string, req interfaces.Request, res interfaces.Response, loc oerror.LineOfCode, deadline time.Time, invoke Invoker) {
+	if req.GetID() == "" {
+		if id := TraceID(ctx); id != "" {
+			req.SetID(id)
+		}
+	}
 
+	if err := s.propagationLimit(req); err != nil {
+		res.SetError(err.SetLineOfCode(loc))
 		return
 	}
+
+	// the deadline, the call chain and the traceparent are only set for this
+	// call, so the request can be sent again later. The deadline inherited
+	// with request.Merge is kept if it is earlier
+	if !deadline.IsZero() {
+		defer setCallMeta(req, request.DeadlineKey, req.GetMetaProp(request.DeadlineKey))()
+		request.SetDeadline(req, deadline)
+	}
+	defer setCallMeta(req, callChainKey, strings.Join(append(callChain(req), s.Name), ","))()
+
+	span := s.startClientSpan(ctx, route, req)
+	defer setCallMeta(req, tracing.TraceparentKey, span.Context().Traceparent())()
+	start := time.Now()
+	invoke(ctx, req, res)
+	s.instruments.observeCall(route, res, time.Since(start))
+	finishSpan(span, res)
+}
+
+// invoker returns the innermost Invoker: it encodes the request, sends it over
+// the transport and decodes the reply. Errors point to loc, the line of code
+// that issued the call
+func (s *Service) invoker(loc oerror.LineOfCode) Invoker {
+	return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+		encoded, err := s.Codec.Encode(req)
+		if err != nil {
+			res.SetError(oerror.New("ORION_ENCODE").SetMessage(err.Error()).SetLineOfCode(loc))
+			return
+		}
+
+		timeout := s.callTimeout(ctx, req)
+		if timeout <= 0 {
+			res.SetError(oerror.New("ORION_DEADLINE_EXCEEDED").SetMessage("the deadline of the request is over").SetLineOfCode(loc))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		path := replaceOmitEmpty(req.GetPath(), "/", ".")
+		if id, ok := ctx.Value(instanceKey{}).(string); ok {
+			path = instanceRoute(path, id)
+		}
+		b, err := s.request(ctx, path, encoded)
+		if err != nil {
+			code := "ORION_TRANSPORT"
+			if ctx.Err() == context.Canceled {
+				code = "ORION_CANCELED"
+			}
+			res.SetError(oerror.New(code).SetMessage(err.Error()).SetLineOfCode(loc))
+			return
+		}
+
+		err = s.Codec.Decode(b, res)
+		if err != nil {
+			res.SetError(oerror.New("ORION_DECODE").SetMessage(err.Error()).SetLineOfCode(loc))
+
+			s.Logger.
+				CreateMessage("ORION_DECODE " + req.GetPath()).
+				SetLevel(logger.ERROR).
+				SetID(req.GetID()).
+				SetMap(map[string]interface{}{
+					"error": res.GetError(),
+				}).
+				SetLineOfCode(loc).
+				Send()
+
+			return
+		}
+	}
 }
 
 type responseWithAnyPayload struct {
@@ -274,14 +700,47 @@ func (s *Service) loopOverHealthChecks() {
 func (s *Service) Listen(callback func()) {
 	if !s.DisableHealthChecks {
 		s.loopOverHealthChecks()
-		s.HTTPServer = health.StartHTTPServer(":" + strconv.Itoa(s.HTTPPort))
+		s.HTTPServer = health.StartHTTPServer(":"+strconv.Itoa(s.HTTPPort), s.installCircuits, s.installRoutes, s.installMetrics)
 	}
 
+	s.announce()
 	s.Transport.Listen(callback)
 }
 
-// Close the transport protocol
+// Drain stops accepting new requests and waits up to the timeout for the ones
+// in flight to be replied. Then the transport is flushed. It returns how many
+// requests were abandoned, i.e. dropped by the transport or still not replied
+// when the timeout expired
+func (s *Service) Drain(timeout time.Duration) int {
+	dropped, _ := s.drainTransport()
+
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&s.pending) > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+
+	s.flushTransport()
+
+	return dropped + int(atomic.LoadInt64(&s.pending))
+}
+
+// Close the transport protocol. The service leaves the registry first, then
+// it is drained when DrainTimeout is set
 func (s *Service) Close() {
+	s.leave()
+
+	if s.DrainTimeout > 0 {
+		if abandoned := s.Drain(s.DrainTimeout); abandoned > 0 {
+			s.Logger.
+				CreateMessage("drain timeout").
+				SetLevel(logger.WARNING).
+				SetMap(map[string]interface{}{
+					"abandoned": abandoned,
+				}).
+				Send()
+		}
+	}
+
 	if s.StopHealthCheck != nil {
 		s.StopHealthCheck <- struct{}{}
 		s.StopHealthCheck = nil
@@ -292,7 +751,13 @@ func (s *Service) Close() {
 		s.HTTPServer = nil
 	}
 
+	s.cancel()
 	s.Transport.Close()
+	s.Tracer.Close()
+
+	for _, pool := range s.routePools {
+		pool.release()
+	}
 }
 
 // OnClose adds a handler to a transport connection closed event
@@ -307,13 +772,25 @@ func (s *Service) String() string {
 	return fmt.Sprintf("%s-%s", s.Name, s.ID)
 }
 
+// requestParams returns the Params field of the request. Custom requests
+// shadow the raw params of request.Request with a typed field
+func requestParams(req interfaces.Request) interface{} {
+	v := reflect.ValueOf(req)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		if f := v.Elem().FieldByName("Params"); f.IsValid() && f.CanInterface() {
+			return f.Interface()
+		}
+	}
+	return req.GetParams()
+}
+
 func (s *Service) logRequest(err error, raw interface{}, logLevel int) {
 	if logLevel != logger.NONE {
 
 		req, ok := raw.(interfaces.Request)
 		checkRequestCast(ok)
 
-		v := reflect.ValueOf(raw).Elem().FieldByName("Params").Interface()
+		v := requestParams(req)
 
 		var out interface{}
 		var in interface{}
@@ -346,7 +823,7 @@ func (s *Service) logRequest(err error, raw interface{}, logLevel int) {
 	}
 }
 
-func (s Service) logResponse(rawReq, rawRes interface{}, logLevel int) {
+func (s *Service) logResponse(rawReq, rawRes interface{}, logLevel int) {
 	if logLevel != logger.NONE {
 
 		req, ok := rawReq.(interfaces.Request)
@@ -368,7 +845,7 @@ func (s Service) logResponse(rawReq, rawRes interface{}, logLevel int) {
 	}
 }
 
-func (s Service) getTimeout(req interfaces.Request) int {
+func (s *Service) getTimeout(req interfaces.Request) int {
 	t := req.GetTimeout()
 	if t != nil {
 		return *t
@@ -376,27 +853,94 @@ func (s Service) getTimeout(req interfaces.Request) int {
 	return s.Timeout
 }
 
-func (s Service) checkHandler(method reflect.Value) {
-	if method.Type().NumIn() != 1 {
-		log.Fatal(errors.New("handler methods must have one argument"))
+// callTimeout returns the time to wait for the reply. The request timeout (or
+// the service one) is used unless the request does not set one and the context
+// has a deadline. It never goes past the x-deadline of the request
+func (s *Service) callTimeout(ctx context.Context, req interfaces.Request) time.Duration {
+	timeout := time.Duration(s.getTimeout(req)) * time.Millisecond
+	if deadline, ok := ctx.Deadline(); ok && req.GetTimeout() == nil {
+		timeout = time.Until(deadline)
 	}
-
-	if method.Type().NumOut() != 1 {
-		log.Fatal(errors.New("handler methods must have one return value"))
+	if deadline, ok := request.Deadline(req); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = remaining
+		}
 	}
+	return timeout
 }
 
-func (s Service) getRouteFromPath(path string) string {
-	parts := strings.Split(path, "/")
-	switch len(parts) {
+func (s *Service) checkHandler(method reflect.Value) {
+	switch method.Type().NumIn() {
 	case 1:
-		return fmt.Sprintf("%s.%s", s.Name, path)
 	case 2:
-		return fmt.Sprintf("%s.%s", parts[0], parts[1])
+		if method.Type().In(0) != contextType {
+			log.Fatal(errors.New("handler methods with two arguments must take a context.Context first"))
+		}
 	default:
-		log.Fatal(errors.New("handler path cannot contain more than one slash"))
-		return fmt.Sprintf("%s.%s", s.Name, path)
+		log.Fatal(errors.New("handler methods must have one argument or a context.Context and one argument"))
 	}
+
+	if method.Type().NumOut() != 1 {
+		log.Fatal(errors.New("handler methods must have one return value"))
+	}
+}
+
+// getRouteFromPath maps a handler path to its route. A single segment is a
+// method of the service, otherwise the first segment is the service name, e.g.
+// users/v2/profile/get is routed to users.v2.profile.get. A segment after the
+// service name can be the * wildcard, matching one segment, or the > one,
+// matching the rest
+func (s *Service) getRouteFromPath(path string) string {
+	parts := strings.Split(replaceOmitEmpty(path, "/", "/"), "/")
+	if parts[0] == "" {
+		log.Fatal(errors.New("handler path cannot be empty"))
+	}
+	if len(parts) == 1 {
+		parts = []string{s.Name, parts[0]}
+	}
+
+	if strings.ContainsAny(parts[0], "*>") {
+		log.Fatal(errors.New("handler path cannot have a wildcard as service name: " + path))
+	}
+
+	for i, part := range parts {
+		if strings.ContainsAny(part, "*>") && part != "*" && part != ">" {
+			log.Fatal(errors.New("handler path wildcards must be a whole segment: " + path))
+		}
+		if part == ">" && i != len(parts)-1 {
+			log.Fatal(errors.New("handler path can only have the > wildcard as last segment: " + path))
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// routesFor a handler path. A versioned route has the version after the
+// service name, e.g. calc.v2.add. The default version is also routed without
+// it, and only one version of a path can be the default
+func (s *Service) routesFor(path string, opts *HandleOptions) (string, []string) {
+	route := s.getRouteFromPath(path)
+	if opts.Version == "" {
+		return route, nil
+	}
+
+	parts := strings.SplitN(route, ".", 2)
+	versioned := parts[0] + "." + opts.Version + "." + parts[1]
+	if !opts.DefaultVersion {
+		return versioned, nil
+	}
+
+	if current, ok := s.routes.setDefault(route, opts.Version); !ok {
+		log.Fatal(errors.New("handler path " + path + " already has " + current + " as default version"))
+	}
+	return versioned, []string{route}
+}
+
+// instanceRoute addresses the route of a single instance, e.g. calc@<id>.add
+func instanceRoute(route string, id string) string {
+	parts := strings.SplitN(route, ".", 2)
+	parts[0] = UniqueName(parts[0], id)
+	return strings.Join(parts, ".")
 }
 
 func replaceOmitEmpty(str string, split string, join string) string {
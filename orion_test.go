@@ -1,11 +1,22 @@
 package orion
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/registry"
+	"github.com/gig/orion-go-sdk/request"
+	"github.com/gig/orion-go-sdk/tracing"
+	"github.com/gig/orion-go-sdk/transport/nats"
+	"github.com/go-chi/chi"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -207,6 +218,20 @@ func TestPathCalculation(t *testing.T) {
 
 	route = svc.getRouteFromPath("module/action")
 	assert.Equal(t, "module.action", route)
+
+	route = svc.getRouteFromPath("/users/v2/profile/get")
+	assert.Equal(t, "users.v2.profile.get", route)
+
+	route = svc.getRouteFromPath("admin/>")
+	assert.Equal(t, "admin.>", route)
+
+	route, aliases := svc.routesFor("calc/add", newHandleOptions([]HandleOption{WithVersion("v2"), AsDefaultVersion()}))
+	assert.Equal(t, "calc.v2.add", route)
+	assert.Equal(t, []string{"calc.add"}, aliases)
+
+	current, ok := svc.routes.setDefault("calc.add", "v3")
+	assert.False(t, ok)
+	assert.Equal(t, "v2", current)
 }
 
 func TestCustomModuleName(t *testing.T) {
@@ -258,6 +283,1946 @@ func TestCustomModuleName(t *testing.T) {
 	assert.Equal(t, expected, result)
 }
 
+func TestMiddleware(t *testing.T) {
+	done := make(chan *Response)
+
+	mw := New("middleware", DisableHealthChecks)
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, req interfaces.Request) interfaces.Response {
+				order = append(order, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	deny := func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req interfaces.Request) interfaces.Response {
+			if req.GetMetaProp("token") == "" {
+				return (&Response{}).SetError(ServiceError("UNAUTHORIZED"))
+			}
+			return next(ctx, req)
+		}
+	}
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	handle := func(req *Request) *Response {
+		order = append(order, "handler")
+		return &Response{}
+	}
+
+	mw.Use(trace("service"))
+	mw.Handle("secret", handle, factory, WithMiddleware(trace("route"), deny))
+
+	go mw.Listen(func() {
+		req := &Request{}
+		req.SetPath("/middleware/secret")
+
+		res := &Response{}
+		svc.Call(req, res)
+
+		mw.Close()
+
+		done <- res
+	})
+
+	res := <-done
+	assert.Equal(t, "UNAUTHORIZED", res.GetError().Code)
+	assert.Equal(t, []string{"service", "route"}, order)
+}
+
+func TestInterceptors(t *testing.T) {
+	done := make(chan string)
+
+	echo := New("echo", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	handle := func(req *Request) *Response {
+		res := &Response{}
+		res.SetPayload(req.GetMetaProp("tenant"))
+		return res
+	}
+
+	echo.Handle("tenant", handle, factory)
+
+	caller := New("caller", DisableHealthChecks)
+	caller.Intercept(func(next Invoker) Invoker {
+		return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+			req.SetMetaProp("tenant", "gig")
+			next(ctx, req, res)
+		}
+	})
+	caller.InterceptPath("/echo/tenant", func(next Invoker) Invoker {
+		return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+			next(ctx, req, res)
+
+			var tenant string
+			res.ParsePayload(&tenant)
+			res.SetPayload(tenant + "!")
+		}
+	})
+
+	go echo.Listen(func() {
+		var result string
+
+		req := &Request{}
+		req.SetPath("/echo/tenant")
+
+		res := &Response{}
+		caller.Call(req, res)
+
+		res.ParsePayload(&result)
+
+		echo.Close()
+
+		done <- result
+	})
+
+	result := <-done
+	assert.Equal(t, "gig!", result)
+}
+
+func TestContext(t *testing.T) {
+	type result struct {
+		TraceID  string
+		Canceled *Error
+	}
+	done := make(chan result)
+
+	ctxsvc := New("ctxsvc", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	handle := func(ctx context.Context, req *Request) *Response {
+		res := &Response{}
+		res.SetPayload(TraceID(ctx))
+		return res
+	}
+
+	slow := func(ctx context.Context, req *Request) *Response {
+		<-ctx.Done()
+		return &Response{}
+	}
+
+	ctxsvc.Handle("trace", handle, factory)
+	ctxsvc.Handle("slow", slow, factory)
+
+	go ctxsvc.Listen(func() {
+		var r result
+
+		req := &Request{}
+		req.SetPath("/ctxsvc/trace")
+
+		res := &Response{}
+		svc.CallContext(WithTraceID(context.Background(), "trace-me"), req, res)
+		res.ParsePayload(&r.TraceID)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		req = &Request{}
+		req.SetPath("/ctxsvc/slow").SetTimeout(1000)
+
+		res = &Response{}
+		svc.CallContext(ctx, req, res)
+		r.Canceled = res.GetError()
+
+		ctxsvc.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, "trace-me", r.TraceID)
+	assert.Equal(t, "ORION_CANCELED", r.Canceled.Code)
+}
+
+func TestErrorResponses(t *testing.T) {
+	done := make(chan []*Error)
+
+	faulty := New("faulty", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	handle := func(req *Request) *Response {
+		panic("boom")
+	}
+
+	faulty.Handle("panic", handle, factory)
+
+	go faulty.Listen(func() {
+		var errs []*Error
+
+		req := &Request{}
+		req.SetPath("/faulty/panic")
+
+		res := &Response{}
+		svc.Call(req, res)
+		errs = append(errs, res.GetError())
+
+		// 0xc1 is never used by msgpack, so the request cannot be decoded
+		b, _ := svc.Transport.Request("faulty.panic", []byte{0xc1}, 200)
+
+		res = &Response{}
+		svc.Codec.Decode(b, res)
+		errs = append(errs, res.GetError())
+
+		faulty.Close()
+
+		done <- errs
+	})
+
+	errs := <-done
+	assert.Equal(t, "ORION_INTERNAL", errs[0].Code)
+	assert.Equal(t, "boom", errs[0].Message)
+	assert.Equal(t, "ORION_DECODE", errs[1].Code)
+}
+
+func TestDrain(t *testing.T) {
+	type result struct {
+		Abandoned int
+		Error     *Error
+	}
+	done := make(chan result)
+
+	drain := New("drain", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	handle := func(req *Request) *Response {
+		time.Sleep(50 * time.Millisecond)
+		return &Response{}
+	}
+
+	drain.Handle("slow", handle, factory)
+
+	go drain.Listen(func() {
+		replied := make(chan *Error)
+
+		go func() {
+			req := &Request{}
+			req.SetPath("/drain/slow")
+
+			res := &Response{}
+			svc.Call(req, res)
+
+			replied <- res.GetError()
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+
+		abandoned := drain.Drain(time.Second)
+		drain.Close()
+
+		done <- result{
+			Abandoned: abandoned,
+			Error:     <-replied,
+		}
+	})
+
+	r := <-done
+	assert.Equal(t, 0, r.Abandoned)
+	assert.Nil(t, r.Error)
+}
+
+func TestRoutePool(t *testing.T) {
+	type result struct {
+		Fast       *Error
+		Overloaded int
+		Rejections uint64
+	}
+	done := make(chan result)
+
+	bulkhead := New("bulkhead", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	slow := func(req *Request) *Response {
+		time.Sleep(100 * time.Millisecond)
+		return &Response{}
+	}
+
+	fast := func(req *Request) *Response {
+		return &Response{}
+	}
+
+	bulkhead.Handle("slow", slow, factory, WithPool(1, 1))
+	bulkhead.Handle("fast", fast, factory)
+
+	go bulkhead.Listen(func() {
+		var r result
+		errs := make(chan *Error, 3)
+
+		for i := 0; i < 3; i++ {
+			go func() {
+				req := &Request{}
+				req.SetPath("/bulkhead/slow").SetTimeout(500)
+
+				res := &Response{}
+				svc.Call(req, res)
+
+				errs <- res.GetError()
+			}()
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		req := &Request{}
+		req.SetPath("/bulkhead/fast").SetTimeout(50)
+
+		res := &Response{}
+		svc.Call(req, res)
+		r.Fast = res.GetError()
+
+		for i := 0; i < 3; i++ {
+			if err := <-errs; err != nil && err.Code == "ORION_OVERLOADED" {
+				r.Overloaded++
+			}
+		}
+
+		r.Rejections = bulkhead.Rejections()["bulkhead.slow"]
+
+		bulkhead.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Nil(t, r.Fast)
+	assert.Equal(t, 1, r.Overloaded)
+	assert.Equal(t, uint64(1), r.Rejections)
+}
+
+func TestMaxWait(t *testing.T) {
+	done := make(chan int)
+
+	maxwait := New("maxwait", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	slow := func(req *Request) *Response {
+		time.Sleep(100 * time.Millisecond)
+		return &Response{}
+	}
+
+	maxwait.Handle("slow", slow, factory, WithPool(1, 1), WithMaxWait(30*time.Millisecond))
+
+	go maxwait.Listen(func() {
+		errs := make(chan *Error, 2)
+
+		for i := 0; i < 2; i++ {
+			go func() {
+				req := &Request{}
+				req.SetPath("/maxwait/slow").SetTimeout(500)
+
+				res := &Response{}
+				svc.Call(req, res)
+
+				errs <- res.GetError()
+			}()
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		overloaded := 0
+		for i := 0; i < 2; i++ {
+			if err := <-errs; err != nil && err.Code == "ORION_OVERLOADED" {
+				overloaded++
+			}
+		}
+
+		maxwait.Close()
+
+		done <- overloaded
+	})
+
+	overloaded := <-done
+	assert.Equal(t, 1, overloaded)
+}
+
+func TestSaturatedRoute(t *testing.T) {
+	type result struct {
+		Overloaded int
+		Took       time.Duration
+	}
+	done := make(chan result)
+
+	saturated := New("saturated", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	slow := func(req *Request) *Response {
+		time.Sleep(300 * time.Millisecond)
+		return &Response{}
+	}
+
+	saturated.Handle("slow", slow, factory, WithPool(1, 0))
+
+	go saturated.Listen(func() {
+		go func() {
+			req := &Request{}
+			req.SetPath("/saturated/slow").SetTimeout(500)
+			svc.Call(req, &Response{})
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		// the requests sent while the worker is busy are rejected right away
+		// instead of waiting in the transport for the worker
+		var r result
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			req := &Request{}
+			req.SetPath("/saturated/slow").SetTimeout(500)
+
+			res := &Response{}
+			svc.Call(req, res)
+
+			if err := res.GetError(); err != nil && err.Code == "ORION_OVERLOADED" {
+				r.Overloaded++
+			}
+		}
+		r.Took = time.Since(start)
+
+		saturated.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, 5, r.Overloaded)
+	assert.True(t, r.Took < 150*time.Millisecond, "rejections took %s", r.Took)
+}
+
+func TestRetry(t *testing.T) {
+	type result struct {
+		Error   *Error
+		Attempt string
+		Left    string
+	}
+	done := make(chan result)
+
+	flaky := New("flaky", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	handle := func(req *Request) *Response {
+		res := &Response{}
+		if req.GetMetaProp("x-attempt") != "3" {
+			res.SetError(ServiceError("ORION_OVERLOADED"))
+			return res
+		}
+		res.SetPayload(req.GetMetaProp("x-attempt"))
+		return res
+	}
+
+	flaky.Handle("once", handle, factory)
+
+	caller := New("retrier", DisableHealthChecks)
+	caller.RetryPath("/flaky/once", RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	})
+
+	go flaky.Listen(func() {
+		var r result
+
+		req := &Request{}
+		req.SetPath("/flaky/once")
+
+		res := &Response{}
+		caller.Call(req, res)
+
+		r.Error = res.GetError()
+		res.ParsePayload(&r.Attempt)
+		r.Left = req.GetMetaProp("x-attempt")
+
+		flaky.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Nil(t, r.Error)
+	assert.Equal(t, "3", r.Attempt)
+	assert.Empty(t, r.Left)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	}
+
+	assert.Equal(t, 10*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(4))
+	assert.False(t, policy.retryable("ORION_DECODE"))
+	assert.True(t, policy.retryable("ORION_TRANSPORT"))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	done := make(chan []*Error)
+
+	caller := New("breaker", DisableHealthChecks)
+	caller.EnableCircuitBreaker(CircuitBreakerOptions{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	})
+
+	go func() {
+		var errs []*Error
+
+		for i := 0; i < 3; i++ {
+			req := &Request{}
+			req.SetPath("/nobody/home").SetTimeout(20)
+
+			res := &Response{}
+			caller.Call(req, res)
+
+			errs = append(errs, res.GetError())
+		}
+
+		done <- errs
+	}()
+
+	errs := <-done
+	assert.Equal(t, "ORION_TRANSPORT", errs[0].Code)
+	assert.Equal(t, "ORION_TRANSPORT", errs[1].Code)
+	assert.Equal(t, "ORION_CIRCUIT_OPEN", errs[2].Code)
+	assert.Equal(t, CircuitOpen, caller.Circuits()[0].State)
+}
+
+func TestCircuitHalfOpen(t *testing.T) {
+	options := CircuitBreakerOptions{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Second,
+	}
+	now := time.Now()
+	c := &circuit{state: CircuitClosed}
+
+	c.record(options, true, now)
+	assert.False(t, c.allow(options, now))
+
+	// a single probe is let through once the timeout is over
+	now = now.Add(time.Second)
+	assert.True(t, c.allow(options, now))
+	assert.False(t, c.allow(options, now))
+
+	c.record(options, false, now)
+	assert.Equal(t, CircuitClosed, c.status("").State)
+	assert.True(t, c.allow(options, now))
+}
+
+func TestCallAll(t *testing.T) {
+	type result struct {
+		Async   interface{}
+		All     []*Error
+		First   []int
+		Clamped int
+		None    []Invocation
+		Calls   []Invocation
+	}
+	done := make(chan result)
+
+	fanout := New("fanout", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	handle := func(req *Request) *Response {
+		var delay int
+		req.ParseParams(&delay)
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+
+		res := &Response{}
+		res.SetPayload(delay)
+		return res
+	}
+
+	fanout.Handle("sleep", handle, factory, WithPool(4, 0))
+
+	call := func(delay int) Invocation {
+		req := &Request{}
+		req.SetPath("/fanout/sleep").SetParams(delay)
+		return Invocation{Request: req, Response: &Response{}}
+	}
+
+	go fanout.Listen(func() {
+		var r result
+		ctx := context.Background()
+
+		res := <-svc.CallAsync(ctx, call(0).Request, &Response{})
+		r.Async = res.GetError()
+
+		r.Clamped = len(svc.CallFirst(ctx, 5, call(0), call(10)))
+
+		r.Calls = []Invocation{call(0), call(10)}
+		r.None = append(svc.CallFirst(ctx, 0, r.Calls...), svc.CallFirst(ctx, -1, r.Calls...)...)
+
+		calls := []Invocation{call(0), call(10), call(1000)}
+		svc.CallAll(ctx, calls...)
+		for _, c := range calls {
+			r.All = append(r.All, c.Response.GetError())
+		}
+
+		calls = []Invocation{call(500), call(0), call(20)}
+		for _, c := range svc.CallFirst(ctx, 1, calls...) {
+			var delay int
+			c.Response.ParsePayload(&delay)
+			r.First = append(r.First, delay)
+		}
+
+		fanout.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Nil(t, r.Async)
+	assert.Nil(t, r.All[0])
+	assert.Nil(t, r.All[1])
+	assert.Equal(t, "ORION_TRANSPORT", r.All[2].Code)
+	assert.Equal(t, []int{0}, r.First)
+	assert.Equal(t, 2, r.Clamped)
+	assert.Nil(t, r.None)
+	for _, c := range r.Calls {
+		assert.Nil(t, c.Response.(*Response).Payload)
+	}
+}
+
+func TestStream(t *testing.T) {
+	type result struct {
+		Sum    int
+		Chunks int
+		Error  *Error
+	}
+	done := make(chan []result)
+
+	streamer := New("streamer", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	count := func(ctx context.Context, req interfaces.Request, w *StreamWriter) *Error {
+		var n int
+		req.ParseParams(&n)
+
+		for i := 1; i <= n; i++ {
+			if err := w.Send(i); err != nil {
+				return ServiceError("STREAM").SetMessage(err.Error())
+			}
+		}
+
+		if n < 0 {
+			return ServiceError("NEGATIVE")
+		}
+		return nil
+	}
+
+	streamer.HandleStream("count", count, factory)
+
+	go streamer.Listen(func() {
+		var results []result
+
+		for _, n := range []int{50, -1} {
+			var r result
+
+			req := &Request{}
+			req.SetPath("/streamer/count").SetParams(n)
+
+			stream := svc.CallStream(context.Background(), req)
+			for stream.Next() {
+				var i int
+				stream.Decode(&i)
+				r.Sum += i
+				r.Chunks++
+			}
+			stream.Close()
+
+			r.Error = stream.Err()
+			results = append(results, r)
+		}
+
+		streamer.Close()
+
+		done <- results
+	})
+
+	results := <-done
+	assert.Nil(t, results[0].Error)
+	assert.Equal(t, 50, results[0].Chunks)
+	assert.Equal(t, 1275, results[0].Sum)
+	assert.Equal(t, "NEGATIVE", results[1].Error.Code)
+}
+
+func TestDrainStream(t *testing.T) {
+	type result struct {
+		Chunks    int
+		Error     *Error
+		Abandoned int
+	}
+	done := make(chan result)
+
+	streamer := New("drainstream", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	count := func(ctx context.Context, req interfaces.Request, w *StreamWriter) *Error {
+		for i := 1; i <= 50; i++ {
+			if err := w.Send(i); err != nil {
+				return ServiceError("STREAM").SetMessage(err.Error())
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	}
+
+	streamer.HandleStream("count", count, factory)
+
+	go streamer.Listen(func() {
+		var r result
+		abandoned := make(chan int)
+
+		req := &Request{}
+		req.SetPath("/drainstream/count")
+
+		stream := svc.CallStream(context.Background(), req)
+		for stream.Next() {
+			// the acks of the stream must still be received while draining
+			if r.Chunks == 0 {
+				go func() {
+					abandoned <- streamer.Drain(time.Second)
+				}()
+			}
+			r.Chunks++
+		}
+		stream.Close()
+
+		r.Error = stream.Err()
+		r.Abandoned = <-abandoned
+		streamer.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Nil(t, r.Error)
+	assert.Equal(t, 50, r.Chunks)
+	assert.Equal(t, 0, r.Abandoned)
+}
+
+func TestStreamRequestSteps(t *testing.T) {
+	type result struct {
+		Route   string
+		Expired *Error
+		Metrics string
+	}
+	done := make(chan result)
+
+	streamer := New("steps", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	route := func(ctx context.Context, req interfaces.Request, w *StreamWriter) *Error {
+		w.Send(MatchedRoute(ctx))
+		return nil
+	}
+
+	streamer.HandleStream("route", route, factory)
+
+	go streamer.Listen(func() {
+		var r result
+
+		req := &Request{}
+		req.SetPath("/steps/route")
+
+		stream := svc.CallStream(context.Background(), req)
+		for stream.Next() {
+			stream.Decode(&r.Route)
+		}
+		stream.Close()
+
+		req = &Request{}
+		req.SetPath("/steps/route")
+		request.SetDeadline(req, time.Now().Add(-time.Second))
+
+		stream = svc.CallStream(context.Background(), req)
+		for stream.Next() {
+		}
+		stream.Close()
+		r.Expired = stream.Err()
+
+		var b bytes.Buffer
+		streamer.Metrics().Write(&b)
+		r.Metrics = b.String()
+
+		streamer.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, "steps.route", r.Route)
+	assert.Equal(t, "ORION_DEADLINE_EXCEEDED", r.Expired.Code)
+	assert.Contains(t, r.Metrics, `orion_requests_total{route="steps.route"} 2`)
+	assert.Contains(t, r.Metrics, `orion_request_errors_total{route="steps.route",code="ORION_DEADLINE_EXCEEDED"} 1`)
+}
+
+func TestCallStreamSteps(t *testing.T) {
+	type result struct {
+		Tenant   string
+		Meta     map[string]string
+		Canceled *Error
+		Handler  error
+	}
+	done := make(chan result)
+	stopped := make(chan error, 1)
+
+	streamer := New("callsteps", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	tenant := func(ctx context.Context, req interfaces.Request, w *StreamWriter) *Error {
+		w.Send(req.GetMetaProp("tenant"))
+		return nil
+	}
+
+	wait := func(ctx context.Context, req interfaces.Request, w *StreamWriter) *Error {
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return nil
+	}
+
+	streamer.HandleStream("tenant", tenant, factory)
+	streamer.HandleStream("wait", wait, factory)
+
+	caller := New("streamcaller", DisableHealthChecks)
+	caller.InterceptPath("/callsteps/tenant", func(next Invoker) Invoker {
+		return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+			req.SetMetaProp("tenant", "gig")
+			next(ctx, req, res)
+		}
+	})
+
+	go streamer.Listen(func() {
+		var r result
+
+		req := &Request{}
+		req.SetPath("/callsteps/tenant")
+
+		stream := caller.CallStream(context.Background(), req)
+		for stream.Next() {
+			stream.Decode(&r.Tenant)
+		}
+		stream.Close()
+		r.Meta = req.GetMeta()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		req = &Request{}
+		req.SetPath("/callsteps/wait")
+
+		stream = caller.CallStream(ctx, req)
+		stream.Close()
+		r.Canceled = stream.Err()
+
+		select {
+		case r.Handler = <-stopped:
+		case <-time.After(time.Second):
+		}
+
+		streamer.Close()
+		caller.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, "gig", r.Tenant)
+	assert.Equal(t, map[string]string{"tenant": "gig"}, r.Meta)
+	assert.Equal(t, "ORION_CANCELED", r.Canceled.Code)
+	assert.Equal(t, context.Canceled, r.Handler)
+}
+
+func TestIdempotency(t *testing.T) {
+	type result struct {
+		Same  []int
+		Other int
+		Runs  int32
+	}
+	done := make(chan result)
+
+	payments := New("payments", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	var runs int32
+	charge := func(req *Request) *Response {
+		n := atomic.AddInt32(&runs, 1)
+		time.Sleep(50 * time.Millisecond)
+
+		res := &Response{}
+		res.SetPayload(n)
+		return res
+	}
+
+	payments.Handle("charge", charge, factory, WithPool(4, 0), WithIdempotency(time.Minute))
+
+	call := func(key string) Invocation {
+		req := &Request{}
+		req.SetPath("/payments/charge").SetMetaProp("idempotency-key", key)
+		return Invocation{Request: req, Response: &Response{}}
+	}
+
+	go payments.Listen(func() {
+		var r result
+		ctx := context.Background()
+
+		calls := []Invocation{call("a"), call("a"), call("a")}
+		svc.CallAll(ctx, calls...)
+		calls = append(calls, call("a"))
+		svc.CallContext(ctx, calls[3].Request, calls[3].Response)
+
+		for _, c := range calls {
+			var n int
+			c.Response.ParsePayload(&n)
+			r.Same = append(r.Same, n)
+		}
+
+		other := call("b")
+		svc.CallContext(ctx, other.Request, other.Response)
+		other.Response.ParsePayload(&r.Other)
+
+		r.Runs = atomic.LoadInt32(&runs)
+
+		payments.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, []int{1, 1, 1, 1}, r.Same)
+	assert.Equal(t, 2, r.Other)
+	assert.Equal(t, int32(2), r.Runs)
+}
+
+func TestCache(t *testing.T) {
+	type result struct {
+		Values  []int
+		Runs    int32
+		NoStore int32
+	}
+	done := make(chan result)
+
+	catalog := New("catalog", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	var runs, noStore int32
+	get := func(req *Request) *Response {
+		atomic.AddInt32(&runs, 1)
+
+		var id int
+		req.ParseParams(&id)
+
+		res := &Response{}
+		res.SetPayload(id * 10)
+		return res
+	}
+
+	volatile := func(req *Request) *Response {
+		atomic.AddInt32(&noStore, 1)
+
+		res := &Response{}
+		res.SetMetaProp("cache-control", "no-store")
+		return res
+	}
+
+	catalog.Handle("get", get, factory)
+	catalog.Handle("volatile", volatile, factory)
+
+	client := New("catalog-client", DisableHealthChecks)
+	client.CachePath("/catalog/get", time.Minute)
+	client.CachePath("/catalog/volatile", time.Minute)
+
+	go catalog.Listen(func() {
+		var r result
+
+		for _, id := range []int{1, 1, 2, 1} {
+			req := &Request{}
+			req.SetPath("/catalog/get").SetParams(id)
+
+			res := &Response{}
+			client.Call(req, res)
+
+			var value int
+			res.ParsePayload(&value)
+			r.Values = append(r.Values, value)
+		}
+
+		for i := 0; i < 2; i++ {
+			req := &Request{}
+			req.SetPath("/catalog/volatile")
+			client.Call(req, &Response{})
+		}
+
+		r.Runs = atomic.LoadInt32(&runs)
+		r.NoStore = atomic.LoadInt32(&noStore)
+
+		client.Close()
+		catalog.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, []int{10, 10, 20, 10}, r.Values)
+	assert.Equal(t, int32(2), r.Runs)
+	assert.Equal(t, int32(2), r.NoStore)
+}
+
+func TestCoalesce(t *testing.T) {
+	type result struct {
+		Values []int
+		Runs   int32
+		Saved  uint64
+	}
+	done := make(chan result)
+
+	hot := New("hot", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	var runs int32
+	get := func(req *Request) *Response {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(50 * time.Millisecond)
+
+		res := &Response{}
+		res.SetPayload(42)
+		return res
+	}
+
+	hot.Handle("key", get, factory, WithPool(8, 0))
+
+	client := New("hot-client", DisableHealthChecks)
+	client.CoalescePath("/hot/key")
+
+	go hot.Listen(func() {
+		var r result
+
+		calls := make([]Invocation, 5)
+		for i := range calls {
+			req := &Request{}
+			req.SetPath("/hot/key").SetParams("a")
+			calls[i] = Invocation{Request: req, Response: &Response{}}
+		}
+		client.CallAll(context.Background(), calls...)
+
+		for _, c := range calls {
+			var value int
+			c.Response.ParsePayload(&value)
+			r.Values = append(r.Values, value)
+		}
+
+		r.Runs = atomic.LoadInt32(&runs)
+		r.Saved = client.Coalesced()["hot.key"]
+
+		client.Close()
+		hot.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, []int{42, 42, 42, 42, 42}, r.Values)
+	assert.Equal(t, int32(1), r.Runs)
+	assert.Equal(t, uint64(4), r.Saved)
+}
+
+func TestCoalesceWaiterTimeout(t *testing.T) {
+	type result struct {
+		Leader  int
+		Short   *Error
+		Elapsed time.Duration
+		Long    int
+		Runs    int32
+	}
+	done := make(chan result)
+
+	slow := New("slowkey", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	var runs int32
+	get := func(req *Request) *Response {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(100 * time.Millisecond)
+
+		res := &Response{}
+		res.SetPayload(42)
+		return res
+	}
+
+	slow.Handle("key", get, factory, WithPool(8, 0))
+
+	client := New("slowkey-client", DisableHealthChecks)
+	client.CoalescePath("/slowkey/key")
+
+	call := func(timeout time.Duration) Invocation {
+		req := &Request{}
+		req.SetPath("/slowkey/key").SetParams("a")
+		if timeout > 0 {
+			req.SetTimeoutDuration(timeout)
+		}
+		return Invocation{Request: req, Response: &Response{}}
+	}
+
+	go slow.Listen(func() {
+		var r result
+		ctx := context.Background()
+
+		leader := call(0)
+		landed := client.CallAsync(ctx, leader.Request, leader.Response)
+		time.Sleep(20 * time.Millisecond)
+
+		short, long := call(20*time.Millisecond), call(0)
+		elapsed := make(chan time.Duration, 1)
+		go func() {
+			start := time.Now()
+			client.CallContext(ctx, short.Request, short.Response)
+			elapsed <- time.Since(start)
+		}()
+		client.CallContext(ctx, long.Request, long.Response)
+		(<-landed).ParsePayload(&r.Leader)
+		r.Elapsed = <-elapsed
+
+		r.Short = short.Response.GetError()
+		long.Response.ParsePayload(&r.Long)
+		r.Runs = atomic.LoadInt32(&runs)
+
+		client.Close()
+		slow.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, 42, r.Leader)
+	assert.Equal(t, "ORION_TRANSPORT", r.Short.Code)
+	assert.True(t, r.Elapsed < 60*time.Millisecond)
+	assert.Equal(t, 42, r.Long)
+	assert.Equal(t, int32(1), r.Runs)
+}
+
+func TestHedging(t *testing.T) {
+	type result struct {
+		Attempt  string
+		Unhedged string
+		TraceIDs []string
+		Elapsed  time.Duration
+	}
+	done := make(chan result)
+
+	tail := New("tail", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	var mu sync.Mutex
+	var traceIDs []string
+	get := func(req *Request) *Response {
+		attempt := req.GetMetaProp("x-hedge-attempt")
+		if attempt != "2" {
+			time.Sleep(300 * time.Millisecond)
+		}
+
+		mu.Lock()
+		traceIDs = append(traceIDs, req.GetID())
+		mu.Unlock()
+
+		res := &Response{}
+		res.SetPayload(attempt)
+		return res
+	}
+
+	tail.Handle("get", get, factory, WithPool(4, 0))
+	tail.Handle("set", get, factory, WithPool(4, 0))
+
+	client := New("tail-client", DisableHealthChecks)
+	client.DeclareIdempotent("/tail/get")
+	client.HedgePath("/tail/get", 30*time.Millisecond)
+	client.HedgePath("/tail/set", 30*time.Millisecond)
+
+	go tail.Listen(func() {
+		var r result
+
+		start := time.Now()
+		req := &Request{}
+		req.SetPath("/tail/get").SetID("hedged")
+		res := &Response{}
+		client.Call(req, res)
+		res.ParsePayload(&r.Attempt)
+		r.Elapsed = time.Since(start)
+
+		req = &Request{}
+		req.SetPath("/tail/set")
+		res = &Response{}
+		client.Call(req, res)
+		res.ParsePayload(&r.Unhedged)
+
+		time.Sleep(300 * time.Millisecond)
+		mu.Lock()
+		r.TraceIDs = traceIDs[:2]
+		mu.Unlock()
+
+		client.Close()
+		tail.Close()
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, "2", r.Attempt)
+	assert.True(t, r.Elapsed < 200*time.Millisecond)
+	assert.Equal(t, "", r.Unhedged)
+	assert.Equal(t, []string{"hedged", "hedged"}, r.TraceIDs)
+}
+
+func TestRegistry(t *testing.T) {
+	type result struct {
+		Joined   registry.Instance
+		Services []string
+		Left     string
+	}
+	done := make(chan result)
+
+	reg := registry.New(svc.Transport, svc.Codec)
+	reg.Start()
+	defer reg.Close()
+
+	events, stop := reg.Watch()
+	defer stop()
+
+	announced := New("announced", DisableHealthChecks, SetVersion("1.2.0"), SetAnnounceInterval(50*time.Millisecond))
+	announced.Handle("ping", func(req *Request) *Response {
+		return &Response{}
+	}, func() interfaces.Request {
+		return &Request{}
+	})
+
+	go announced.Listen(func() {
+		var r result
+
+		for e := range events {
+			if e.Instance.Name == "announced" && e.Type == registry.Join {
+				r.Joined = e.Instance
+				break
+			}
+		}
+		r.Services = reg.Services()
+
+		announced.Close()
+
+		for e := range events {
+			if e.Instance.Name == "announced" && e.Type == registry.Leave {
+				r.Left = e.Instance.ID
+				break
+			}
+		}
+
+		done <- r
+	})
+
+	r := <-done
+	assert.Equal(t, announced.ID, r.Joined.ID)
+	assert.Equal(t, "1.2.0", r.Joined.Version)
+	assert.Equal(t, []string{"announced.ping"}, r.Joined.Routes)
+	assert.Contains(t, r.Services, "announced")
+	assert.Equal(t, announced.ID, r.Left)
+}
+
+func TestRegistryClose(t *testing.T) {
+	closed := registry.New(svc.Transport, svc.Codec)
+	closed.Start()
+
+	open := registry.New(svc.Transport, svc.Codec)
+	open.Start()
+	defer open.Close()
+
+	events, stop := open.Watch()
+	defer stop()
+
+	closed.Close()
+
+	b, _ := svc.Codec.Encode(registry.Announcement{Name: "kept", ID: "1", Interval: 1000})
+	svc.Transport.Publish(registry.Subject, b)
+
+	select {
+	case e := <-events:
+		assert.Equal(t, registry.Join, e.Type)
+		assert.Equal(t, "kept", e.Instance.Name)
+	case <-time.After(time.Second):
+		t.Fatal("the open registry did not receive the announcement")
+	}
+	assert.Empty(t, closed.Services())
+}
+
+func TestLeaveBeforeDrain(t *testing.T) {
+	done := make(chan []string)
+
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	leaving := New("leaving", DisableHealthChecks, SetAnnounceInterval(5*time.Millisecond), SetDrainTimeout(time.Second))
+
+	started := make(chan struct{})
+	leaving.Handle("slow", func(req *Request) *Response {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		record("replied")
+		return &Response{}
+	}, func() interfaces.Request {
+		return &Request{}
+	})
+
+	observer := New("observer", DisableHealthChecks)
+	observer.Transport.Subscribe(registry.Subject, "", func(b []byte) {
+		var a registry.Announcement
+		if observer.Codec.Decode(b, &a) != nil || a.ID != leaving.ID {
+			return
+		}
+		if a.Leaving {
+			record("leaving")
+		} else {
+			record("announce")
+		}
+	})
+
+	go leaving.Listen(func() {
+		req := &Request{}
+		req.SetPath("/leaving/slow")
+		go svc.Call(req, &Response{})
+
+		<-started
+		leaving.Close()
+		// a late announcement would be received by now
+		time.Sleep(20 * time.Millisecond)
+		observer.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		done <- append([]string{}, events...)
+	})
+
+	r := <-done
+	assert.Equal(t, "announce", r[0])
+	assert.Equal(t, []string{"leaving", "replied"}, r[len(r)-2:])
+}
+
+func TestToInstance(t *testing.T) {
+	done := make(chan []string)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	instances := []*Service{New("sticky", DisableHealthChecks), New("sticky", DisableHealthChecks)}
+	for _, instance := range instances {
+		id := instance.ID
+		instance.Handle("who", func(req *Request) *Response {
+			res := &Response{}
+			res.SetPayload(id)
+			return res
+		}, factory)
+	}
+
+	target := instances[1]
+
+	go instances[0].Listen(func() {
+		go target.Listen(func() {
+			var ids []string
+
+			for i := 0; i < 4; i++ {
+				req := &Request{}
+				req.SetPath("/sticky/who")
+
+				res := &Response{}
+				svc.Call(req, res, ToInstance(target.ID))
+
+				var id string
+				res.ParsePayload(&id)
+				ids = append(ids, id)
+			}
+
+			instances[0].Close()
+			target.Close()
+
+			done <- ids
+		})
+	})
+
+	ids := <-done
+	assert.Equal(t, []string{target.ID, target.ID, target.ID, target.ID}, ids)
+}
+
+func TestCallBroadcast(t *testing.T) {
+	type result struct {
+		Responses map[string]interfaces.Response
+		Error     error
+	}
+	done := make(chan result)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	instances := []*Service{New("everyone", DisableHealthChecks), New("everyone", DisableHealthChecks)}
+	for _, instance := range instances {
+		id := instance.ID
+		instance.Handle("invalidate", func(req *Request) *Response {
+			res := &Response{}
+			res.SetPayload(id)
+			return res
+		}, factory)
+	}
+
+	go instances[0].Listen(func() {
+		go instances[1].Listen(func() {
+			req := &Request{}
+			req.SetPath("/everyone/invalidate")
+
+			var r result
+			r.Responses, r.Error = svc.CallBroadcast(context.Background(), req, func() interfaces.Response {
+				return &Response{}
+			}, len(instances))
+
+			instances[0].Close()
+			instances[1].Close()
+
+			done <- r
+		})
+	})
+
+	r := <-done
+	responses := r.Responses
+	assert.Nil(t, r.Error)
+	assert.Len(t, responses, 2)
+	for _, instance := range instances {
+		var id string
+		responses[instance.ID].ParsePayload(&id)
+		assert.Equal(t, instance.ID, id)
+	}
+}
+
+func TestCallBroadcastErrors(t *testing.T) {
+	newResponse := func() interfaces.Response {
+		return &Response{}
+	}
+
+	basic := New("basicbroadcaster", DisableHealthChecks, SetTransport(basicTransport{nats.New()}))
+	defer basic.Close()
+
+	req := &Request{}
+	req.SetPath("/nobody/ping")
+
+	responses, err := basic.CallBroadcast(context.Background(), req, newResponse, 0)
+	assert.Empty(t, responses)
+	assert.Equal(t, "ORION_TRANSPORT", err.(*Error).Code)
+
+	guarded := New("guarded", DisableHealthChecks)
+	defer guarded.Close()
+
+	var deadline string
+	guarded.InterceptPath("/nobody/ping", func(next Invoker) Invoker {
+		return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+			deadline = req.GetMetaProp(request.DeadlineKey)
+			res.SetError(ServiceError("DENIED"))
+		}
+	})
+
+	responses, err = guarded.CallBroadcast(context.Background(), req, newResponse, 0)
+	assert.Empty(t, responses)
+	assert.Equal(t, "DENIED", err.(*Error).Code)
+	assert.NotEmpty(t, deadline)
+}
+
+func TestRoutes(t *testing.T) {
+	audited := New("audited", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	audited.HandleWithoutLogging("quiet", func(req *Request) *Response {
+		return &Response{}
+	}, factory, WithPool(2, 5))
+	audited.Handle("loud", func(ctx context.Context, req *Request) *Response {
+		return &Response{}
+	}, factory)
+	audited.On("created", func([]byte) {})
+
+	router := chi.NewRouter()
+	audited.installRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/routes", nil))
+
+	var body struct {
+		Routes []RouteInfo
+		Events []EventInfo
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+
+	audited.Close()
+
+	assert.Equal(t, []RouteInfo{{
+		Route:      "audited.loud",
+		LogLevel:   "info",
+		SharedPool: true,
+		PoolSize:   audited.sharedPool.size,
+		QueueSize:  audited.sharedPool.queue,
+		MaxWait:    "0s",
+		Request:    "*request.Request",
+		Response:   "*response.Response",
+	}, {
+		Route:     "audited.quiet",
+		LogLevel:  "none",
+		PoolSize:  2,
+		QueueSize: 5,
+		MaxWait:   "0s",
+		Request:   "*request.Request",
+		Response:  "*response.Response",
+	}}, body.Routes)
+	assert.Equal(t, []EventInfo{{Subject: "audited:created"}}, body.Events)
+}
+
+func TestMetrics(t *testing.T) {
+	done := make(chan string)
+
+	measured := New("measured", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	measured.Handle("get", func(req *Request) *Response {
+		var fail bool
+		req.ParseParams(&fail)
+
+		res := &Response{}
+		if fail {
+			res.SetError(ServiceError("NOT_FOUND"))
+		}
+		return res
+	}, factory)
+
+	go measured.Listen(func() {
+		for _, fail := range []bool{false, true} {
+			req := &Request{}
+			req.SetPath("/measured/get").SetParams(fail)
+			measured.Call(req, &Response{})
+		}
+		measured.Emit("measured:done", true)
+
+		router := chi.NewRouter()
+		measured.installMetrics(router)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		measured.Close()
+
+		done <- rec.Body.String()
+	})
+
+	body := <-done
+	assert.Contains(t, body, `orion_requests_total{route="measured.get"} 2`)
+	assert.Contains(t, body, `orion_request_errors_total{route="measured.get",code="NOT_FOUND"} 1`)
+	assert.Contains(t, body, `orion_request_duration_seconds_count{route="measured.get"} 2`)
+	assert.Contains(t, body, `orion_call_duration_seconds_count{destination="measured.get"} 2`)
+	assert.Contains(t, body, `orion_call_errors_total{destination="measured.get",code="NOT_FOUND"} 1`)
+	assert.Contains(t, body, `orion_emitted_total{topic="measured:done"} 1`)
+	assert.Contains(t, body, `orion_pool_running{pool="shared"}`)
+}
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*tracing.Span
+}
+
+func (e *recordingExporter) Export(span *tracing.Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+func (e *recordingExporter) Close() error {
+	return nil
+}
+
+func (e *recordingExporter) find(name, kind string) *tracing.Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, span := range e.spans {
+		if span.Name == name && span.Kind == kind {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	done := make(chan string)
+	exporter := &recordingExporter{}
+
+	traced := New("traced", DisableHealthChecks, SetTraceExporter(exporter))
+	client := New("traced-client", DisableHealthChecks, SetTraceExporter(exporter))
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	traced.Handle("outer", func(req *Request) *Response {
+		inner := &Request{}
+		inner.SetPath("/traced/inner")
+		request.Merge(req, inner)
+
+		res := &Response{}
+		traced.Call(inner, res)
+		return res
+	}, factory, WithPool(2, 0))
+	traced.Handle("inner", func(req *Request) *Response {
+		return &Response{Error: ServiceError("NOT_FOUND")}
+	}, factory, WithPool(2, 0))
+
+	go traced.Listen(func() {
+		req := &Request{}
+		req.SetPath("/traced/outer").SetID("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+		client.Call(req, &Response{})
+
+		client.Close()
+		traced.Close()
+
+		done <- req.GetMetaProp(tracing.TraceparentKey)
+	})
+
+	// the client span is only set on the request for the call
+	assert.Empty(t, <-done)
+
+	call := exporter.find("traced.outer", tracing.Client)
+	outer := exporter.find("traced.outer", tracing.Server)
+	innerCall := exporter.find("traced.inner", tracing.Client)
+	inner := exporter.find("traced.inner", tracing.Server)
+
+	assert.Equal(t, "6ba7b8109dad11d180b400c04fd430c8", call.TraceID)
+	assert.Equal(t, "", call.ParentID)
+	assert.Equal(t, call.SpanID, outer.ParentID)
+	assert.Equal(t, outer.SpanID, innerCall.ParentID)
+	assert.Equal(t, innerCall.SpanID, inner.ParentID)
+	assert.Equal(t, call.TraceID, inner.TraceID)
+	assert.Equal(t, "NOT_FOUND", inner.ErrorCode)
+	assert.Equal(t, "NOT_FOUND", call.ErrorCode)
+}
+
+func TestEmitTracing(t *testing.T) {
+	exporter := &recordingExporter{}
+
+	emitter := New("emitter", DisableHealthChecks, SetTraceExporter(exporter))
+	defer emitter.Close()
+
+	handling := emitter.Tracer.Start("emitter.handle", tracing.Server, tracing.SpanContext{}, "")
+	emitter.EmitContext(tracing.ContextWithSpan(context.Background(), handling), "emitter:from-context", true)
+
+	calling := emitter.Tracer.Start("emitter.call", tracing.Client, tracing.SpanContext{}, "")
+	req := &Request{}
+	req.SetMetaProp(tracing.TraceparentKey, calling.Context().Traceparent())
+	emitter.Emit("emitter:from-request", req)
+
+	fromContext := exporter.find("emitter:from-context", tracing.Producer)
+	assert.Equal(t, handling.TraceID, fromContext.TraceID)
+	assert.Equal(t, handling.SpanID, fromContext.ParentID)
+
+	fromRequest := exporter.find("emitter:from-request", tracing.Producer)
+	assert.Equal(t, calling.TraceID, fromRequest.TraceID)
+	assert.Equal(t, calling.SpanID, fromRequest.ParentID)
+	assert.Equal(t, calling.Context().Traceparent(), req.GetMetaProp(tracing.TraceparentKey))
+}
+
+func TestDeadline(t *testing.T) {
+	type result struct {
+		Inner   *Error
+		Took    time.Duration
+		Handled int32
+	}
+	done := make(chan result)
+
+	budget := New("budget", DisableHealthChecks)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	var r result
+	budget.Handle("outer", func(req *Request) *Response {
+		inner := &Request{}
+		inner.SetPath("/budget/inner")
+		request.Merge(req, inner)
+
+		start := time.Now()
+		res := &Response{}
+		budget.Call(inner, res)
+		r.Took = time.Since(start)
+		r.Inner = res.GetError()
+		return res
+	}, factory, WithPool(2, 0))
+	budget.Handle("inner", func(req *Request) *Response {
+		time.Sleep(150 * time.Millisecond)
+		return &Response{}
+	}, factory, WithPool(2, 0))
+
+	var handled int32
+	budget.Handle("single", func(req *Request) *Response {
+		atomic.AddInt32(&handled, 1)
+		time.Sleep(100 * time.Millisecond)
+		return &Response{}
+	}, factory, WithPool(1, 0))
+
+	go budget.Listen(func() {
+		req := &Request{}
+		req.SetPath("/budget/outer").SetTimeout(50)
+		svc.Call(req, &Response{})
+
+		var replies []<-chan interfaces.Response
+		for _, timeout := range []int{500, 30} {
+			req := &Request{}
+			req.SetPath("/budget/single").SetTimeout(timeout)
+			replies = append(replies, svc.CallAsync(context.Background(), req, &Response{}))
+			time.Sleep(10 * time.Millisecond)
+		}
+		for _, reply := range replies {
+			<-reply
+		}
+		time.Sleep(50 * time.Millisecond)
+		r.Handled = atomic.LoadInt32(&handled)
+
+		budget.Close()
+
+		done <- r
+	})
+
+	r = <-done
+	assert.Equal(t, "ORION_TRANSPORT", r.Inner.Code)
+	assert.True(t, r.Took < 100*time.Millisecond)
+	assert.Equal(t, int32(1), r.Handled)
+}
+
+func TestPropagationLimit(t *testing.T) {
+	type result struct {
+		Error   *Error
+		Handled int32
+	}
+	done := make(chan result)
+
+	loop := New("loop", DisableHealthChecks, SetMaxPropagation(3))
+
+	var handled int32
+	loop.Handle("again", func(req *Request) *Response {
+		atomic.AddInt32(&handled, 1)
+
+		next := &Request{}
+		next.SetPath("/loop/again")
+		request.Merge(req, next)
+
+		res := &Response{}
+		loop.Call(next, res)
+		return res
+	}, func() interfaces.Request {
+		return &Request{}
+	}, WithPool(8, 0))
+
+	go loop.Listen(func() {
+		req := &Request{}
+		req.SetPath("/loop/again")
+
+		res := &Response{}
+		svc.Call(req, res)
+
+		loop.Close()
+
+		done <- result{Error: res.GetError(), Handled: atomic.LoadInt32(&handled)}
+	})
+
+	r := <-done
+	assert.Equal(t, "ORION_PROPAGATION_LIMIT", r.Error.Code)
+	assert.Contains(t, r.Error.Message, "e2e -> loop -> loop -> loop -> loop")
+	assert.Equal(t, int32(4), r.Handled)
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	done := make(chan []string)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+	version := func(v string) func(*Request) *Response {
+		return func(req *Request) *Response {
+			res := &Response{}
+			res.SetPayload(v)
+			return res
+		}
+	}
+
+	versioned := New("versioned", DisableHealthChecks)
+	versioned.Handle("versioned/profile/get", version("v1"), factory, WithVersion("v1"))
+	versioned.Handle("versioned/profile/get", version("v2"), factory, WithVersion("v2"), AsDefaultVersion())
+
+	go versioned.Listen(func() {
+		var versions []string
+
+		for _, path := range []string{"/versioned/v1/profile/get", "/versioned/v2/profile/get", "/versioned/profile/get"} {
+			req := &Request{}
+			req.SetPath(path)
+
+			res := &Response{}
+			svc.Call(req, res)
+
+			var v string
+			res.ParsePayload(&v)
+			versions = append(versions, v)
+		}
+
+		versioned.Close()
+
+		done <- versions
+	})
+
+	versions := <-done
+	assert.Equal(t, []string{"v1", "v2", "v2"}, versions)
+}
+
+func TestWildcardRoute(t *testing.T) {
+	done := make(chan []string)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	admin := New("admin", DisableHealthChecks)
+	admin.Handle("admin/>", func(ctx context.Context, req *Request) *Response {
+		res := &Response{}
+		res.SetPayload(MatchedRoute(ctx))
+		return res
+	}, factory)
+
+	go admin.Listen(func() {
+		var routes []string
+
+		for _, path := range []string{"/admin/users/list", "/admin/cache/flush"} {
+			req := &Request{}
+			req.SetPath(path)
+
+			res := &Response{}
+			svc.Call(req, res)
+
+			var route string
+			res.ParsePayload(&route)
+			routes = append(routes, route)
+		}
+
+		admin.Close()
+
+		done <- routes
+	})
+
+	routes := <-done
+	assert.Equal(t, []string{"admin.users.list", "admin.cache.flush"}, routes)
+}
+
+// basicTransport hides the optional methods of the transport it wraps
+type basicTransport struct {
+	interfaces.Transport
+}
+
+func TestBasicTransport(t *testing.T) {
+	done := make(chan string)
+
+	factory := func() interfaces.Request {
+		return &Request{}
+	}
+
+	basic := New("basic", DisableHealthChecks)
+	basic.Handle("echo", func(req *Request) *Response {
+		res := &Response{}
+		res.SetPayload("echo")
+		return res
+	}, factory)
+
+	caller := New("caller", DisableHealthChecks, SetTransport(basicTransport{nats.New()}))
+
+	go basic.Listen(func() {
+		req := &Request{}
+		req.SetPath("/basic/echo")
+
+		res := &Response{}
+		caller.Call(req, res)
+
+		var payload string
+		res.ParsePayload(&payload)
+
+		basic.Close()
+		caller.Close()
+
+		done <- payload
+	})
+
+	assert.Equal(t, "echo", <-done)
+}
+
 func TestMain(m *testing.M) {
 	svc = New("e2e", DisableHealthChecks)
 	svc.Listen(func() {
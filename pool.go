@@ -0,0 +1,111 @@
+package orion
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/panjf2000/ants"
+)
+
+var errOverloaded = errors.New("the worker pool is overloaded")
+
+// workerPool runs requests on an ants pool. Up to size requests run and up to
+// queue requests wait for a free worker; the rest are rejected right away
+type workerPool struct {
+	// load is the number of requests running or waiting, accessed atomically
+	load  int64
+	size  int
+	queue int
+	pool  *ants.PoolWithFunc
+}
+
+func newWorkerPool(size, queue int) (*workerPool, error) {
+	pool, err := ants.NewPoolWithFunc(size, func(fn interface{}) {
+		toCall := fn.(func())
+		toCall()
+	}, ants.WithNonblocking(false))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if queue < 0 {
+		queue = 0
+	}
+
+	return &workerPool{
+		size:  size,
+		queue: queue,
+		pool:  pool,
+	}, nil
+}
+
+// submit the task without blocking. rejected is called instead of the task
+// when the pool is overloaded (with errOverloaded) or closed
+func (p *workerPool) submit(task func(), rejected func(error)) {
+	if atomic.AddInt64(&p.load, 1) > int64(p.size+p.queue) {
+		atomic.AddInt64(&p.load, -1)
+		rejected(errOverloaded)
+		return
+	}
+
+	// the transport callback must not block, otherwise the requests would
+	// pile up in the transport instead of the bounded queue
+	go func() {
+		err := p.pool.Invoke(func() {
+			defer atomic.AddInt64(&p.load, -1)
+			task()
+		})
+		if err != nil {
+			atomic.AddInt64(&p.load, -1)
+			rejected(err)
+		}
+	}()
+}
+
+// waiting returns how many requests wait for a free worker
+func (p *workerPool) waiting() int {
+	waiting := int(atomic.LoadInt64(&p.load)) - p.pool.Running()
+	if waiting < 0 {
+		return 0
+	}
+	return waiting
+}
+
+func (p *workerPool) release() {
+	p.pool.Release()
+}
+
+// rejections counts the requests rejected with ORION_OVERLOADED per route
+type rejections struct {
+	mu     sync.Mutex
+	routes map[string]uint64
+}
+
+func (r *rejections) add(route string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.routes == nil {
+		r.routes = map[string]uint64{}
+	}
+	r.routes[route]++
+}
+
+func (r *rejections) counts() map[string]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]uint64, len(r.routes))
+	for route, count := range r.routes {
+		counts[route] = count
+	}
+	return counts
+}
+
+// Rejections returns how many requests were rejected with an ORION_OVERLOADED
+// error per route, because the queue was full or they waited too long
+func (s *Service) Rejections() map[string]uint64 {
+	return s.rejections.counts()
+}
@@ -0,0 +1,78 @@
+package orion
+
+import (
+	"strconv"
+	"strings"
+
+	oerror "github.com/gig/orion-go-sdk/error"
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/logger"
+)
+
+const (
+	propagationKey = "propagation"
+	// callChainKey is the meta with the names of the services a request went
+	// through, separated by commas
+	callChainKey = "x-call-chain"
+)
+
+// propagationLevel of the request, increased by request.Merge on every hop
+func propagationLevel(req interfaces.Request) int {
+	level, _ := strconv.Atoi(req.GetMetaProp(propagationKey))
+	return level
+}
+
+// callChain of the request
+func callChain(req interfaces.Request) []string {
+	chain := req.GetMetaProp(callChainKey)
+	if chain == "" {
+		return nil
+	}
+	return strings.Split(chain, ",")
+}
+
+// propagationLimit returns an ORION_PROPAGATION_LIMIT error when the request
+// went through more hops than MaxPropagation allows
+func (s *Service) propagationLimit(req interfaces.Request) *oerror.Error {
+	level := propagationLevel(req)
+	if s.MaxPropagation <= 0 || level <= s.MaxPropagation {
+		return nil
+	}
+
+	return oerror.New("ORION_PROPAGATION_LIMIT").SetMessage(
+		"the request went through " + strconv.Itoa(level) + " hops, the limit is " + strconv.Itoa(s.MaxPropagation) +
+			": " + strings.Join(append(callChain(req), s.Name), " -> "))
+}
+
+// detectCycle logs the call chain when the service is already part of it
+func (s *Service) detectCycle(req interfaces.Request, service string) {
+	chain := callChain(req)
+	for _, name := range chain {
+		if name == service {
+			s.Logger.
+				CreateMessage("call cycle detected " + req.GetPath()).
+				SetLevel(logger.WARNING).
+				SetID(req.GetID()).
+				SetMap(map[string]interface{}{
+					"chain": strings.Join(append(chain, service), " -> "),
+				}).
+				Send()
+			return
+		}
+	}
+}
+
+// setCallMeta sets the meta for the duration of a call. The returned func
+// restores the previous value, so the request can be sent again later
+func setCallMeta(req interfaces.Request, key, value string) func() {
+	previous, ok := req.GetMeta()[key]
+	req.SetMetaProp(key, value)
+
+	return func() {
+		if ok {
+			req.SetMetaProp(key, previous)
+		} else {
+			delete(req.GetMeta(), key)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package registry
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+)
+
+const (
+	// Subject the services announce themselves on
+	Subject = "orion.registry"
+	// DefaultInterval between the announcements of a service
+	DefaultInterval = 5 * time.Second
+	// missedAnnouncements before an instance is considered stale
+	missedAnnouncements = 3
+	staleCheckInterval  = time.Second
+)
+
+// Event types
+const (
+	Join  = "join"
+	Leave = "leave"
+	Stale = "stale"
+)
+
+// Announcement of a service instance
+type Announcement struct {
+	Name    string   `msgpack:"name" json:"name"`
+	ID      string   `msgpack:"id" json:"id"`
+	Version string   `msgpack:"version" json:"version"`
+	Routes  []string `msgpack:"routes" json:"routes"`
+	Host    string   `msgpack:"host" json:"host"`
+	// Interval in milliseconds until the next announcement
+	Interval int `msgpack:"interval" json:"interval"`
+	// Timestamp in unix milliseconds
+	Timestamp int64 `msgpack:"timestamp" json:"timestamp"`
+	// Leaving is set by the last announcement of a closing instance
+	Leaving bool `msgpack:"leaving" json:"leaving"`
+}
+
+// Instance of a service known by the registry
+type Instance struct {
+	Announcement
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Event of an instance joining, leaving or going stale
+type Event struct {
+	Type     string
+	Instance Instance
+}
+
+// Registry keeps track of the instances announced on the transport
+type Registry struct {
+	transport interfaces.Transport
+	codec     interfaces.Codec
+	mu        sync.Mutex
+	instances map[string]Instance
+	watchers  map[chan Event]struct{}
+	close     chan struct{}
+	closeOnce sync.Once
+	// unsubscribe removes the subscription of the registry, when the
+	// transport can remove it alone
+	unsubscribe func() error
+	// now is the clock the announcements are timed with
+	now func() time.Time
+}
+
+// New registry. It does not receive announcements until it is started
+func New(transport interfaces.Transport, codec interfaces.Codec) *Registry {
+	return &Registry{
+		transport: transport,
+		codec:     codec,
+		instances: map[string]Instance{},
+		watchers:  map[chan Event]struct{}{},
+		close:     make(chan struct{}),
+		now:       time.Now,
+	}
+}
+
+// Start receiving announcements and checking for stale instances
+func (r *Registry) Start() error {
+	handler := func(data []byte) {
+		select {
+		case <-r.close:
+			return
+		default:
+		}
+
+		var a Announcement
+		if r.codec.Decode(data, &a) == nil {
+			r.receive(a, r.now())
+		}
+	}
+
+	if t, ok := r.transport.(interfaces.SingleSubscriber); ok {
+		unsubscribe, err := t.SubscribeSingle(Subject, "", handler)
+		if err != nil {
+			return err
+		}
+		r.unsubscribe = unsubscribe
+	} else if err := r.transport.Subscribe(Subject, "", handler); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(staleCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.close:
+				return
+			case <-ticker.C:
+				r.sweep(r.now())
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close the registry and its watchers. The other subscriptions to the subject
+// on the same transport are kept: when the transport cannot remove the one of
+// the registry alone, the announcements it still delivers are ignored
+func (r *Registry) Close() {
+	r.closeOnce.Do(func() {
+		close(r.close)
+		if r.unsubscribe != nil {
+			r.unsubscribe()
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		for w := range r.watchers {
+			delete(r.watchers, w)
+			close(w)
+		}
+	})
+}
+
+// Services returns the names of the services with live instances
+func (r *Registry) Services() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := map[string]bool{}
+	names := []string{}
+	for _, instance := range r.instances {
+		if !seen[instance.Name] {
+			seen[instance.Name] = true
+			names = append(names, instance.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Instances returns the live instances of the service. An empty name
+// returns the instances of every service
+func (r *Registry) Instances(name string) []Instance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	instances := []Instance{}
+	for _, instance := range r.instances {
+		if name == "" || instance.Name == name {
+			instances = append(instances, instance)
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Name != instances[j].Name {
+			return instances[i].Name < instances[j].Name
+		}
+		return instances[i].ID < instances[j].ID
+	})
+	return instances
+}
+
+// Watch the instances joining, leaving or going stale. Call the returned func
+// to stop watching. Events are dropped for watchers that do not keep up
+func (r *Registry) Watch() (<-chan Event, func()) {
+	w := make(chan Event, 64)
+
+	r.mu.Lock()
+	r.watchers[w] = struct{}{}
+	r.mu.Unlock()
+
+	return w, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if _, ok := r.watchers[w]; ok {
+			delete(r.watchers, w)
+			close(w)
+		}
+	}
+}
+
+func (r *Registry) receive(a Announcement, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	instance, known := r.instances[a.ID]
+
+	if a.Leaving {
+		if known {
+			delete(r.instances, a.ID)
+			r.notify(Event{Type: Leave, Instance: instance})
+		}
+		return
+	}
+
+	instance = Instance{Announcement: a, LastSeen: now}
+	r.instances[a.ID] = instance
+
+	if !known {
+		r.notify(Event{Type: Join, Instance: instance})
+	}
+}
+
+// sweep removes the instances that missed their announcements
+func (r *Registry) sweep(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, instance := range r.instances {
+		if instance.stale(now) {
+			delete(r.instances, id)
+			r.notify(Event{Type: Stale, Instance: instance})
+		}
+	}
+}
+
+func (r *Registry) notify(e Event) {
+	for w := range r.watchers {
+		select {
+		case w <- e:
+		default:
+		}
+	}
+}
+
+func (i Instance) stale(now time.Time) bool {
+	interval := time.Duration(i.Interval) * time.Millisecond
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return now.Sub(i.LastSeen) > missedAnnouncements*interval
+}
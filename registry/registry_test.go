@@ -0,0 +1,120 @@
+package registry
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gig/orion-go-sdk/codec/msgpack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStale(t *testing.T) {
+	r := New(nil, nil)
+	events, stop := r.Watch()
+	defer stop()
+
+	now := time.Now()
+	r.receive(Announcement{Name: "calc", ID: "1", Interval: 100}, now)
+	r.receive(Announcement{Name: "calc", ID: "2", Interval: 1000}, now)
+
+	r.sweep(now.Add(time.Second))
+
+	assert.Equal(t, Join, (<-events).Type)
+	assert.Equal(t, Join, (<-events).Type)
+
+	e := <-events
+	assert.Equal(t, Stale, e.Type)
+	assert.Equal(t, "1", e.Instance.ID)
+
+	instances := r.Instances("calc")
+	assert.Len(t, instances, 1)
+	assert.Equal(t, "2", instances[0].ID)
+}
+
+// busTransport hands the announcements published to its subscribers
+type busTransport struct {
+	mu       sync.Mutex
+	handlers map[string]func([]byte)
+}
+
+func (t *busTransport) Listen(func())                                              {}
+func (t *busTransport) SubscribeForRawMsg(string, string, func(interface{})) error { return nil }
+func (t *busTransport) Handle(string, string, func([]byte, func([]byte))) error    { return nil }
+func (t *busTransport) Request(string, []byte, int) ([]byte, error)                { return nil, nil }
+func (t *busTransport) Close()                                                     {}
+func (t *busTransport) IsOpen() bool                                               { return true }
+func (t *busTransport) OnClose(interface{})                                        {}
+
+func (t *busTransport) Subscribe(topic string, group string, handler func([]byte)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[topic] = handler
+	return nil
+}
+
+func (t *busTransport) Publish(topic string, data []byte) error {
+	t.mu.Lock()
+	handler := t.handlers[topic]
+	t.mu.Unlock()
+	if handler != nil {
+		handler(data)
+	}
+	return nil
+}
+
+// clock moved forward by the test
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestStaleWithClock(t *testing.T) {
+	transport := &busTransport{handlers: map[string]func([]byte){}}
+	codec := msgpack.New()
+	c := &clock{now: time.Now()}
+
+	r := New(transport, codec)
+	r.now = c.Now
+	assert.Nil(t, r.Start())
+	defer r.Close()
+
+	events, stop := r.Watch()
+	defer stop()
+
+	b, _ := codec.Encode(Announcement{Name: "calc", ID: "1", Interval: 100})
+	transport.Publish(Subject, b)
+	assert.Equal(t, Join, (<-events).Type)
+
+	// up to 3 announcements can be missed
+	c.Advance(250 * time.Millisecond)
+	r.sweep(r.now())
+	assert.Len(t, r.Instances("calc"), 1)
+
+	// a new announcement resets the count
+	transport.Publish(Subject, b)
+	c.Advance(250 * time.Millisecond)
+	r.sweep(r.now())
+	assert.Len(t, r.Instances("calc"), 1)
+
+	c.Advance(100 * time.Millisecond)
+	r.sweep(r.now())
+
+	e := <-events
+	assert.Equal(t, Stale, e.Type)
+	assert.Equal(t, "1", e.Instance.ID)
+	assert.Empty(t, r.Instances("calc"))
+	assert.Empty(t, r.Services())
+}
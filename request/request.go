@@ -9,6 +9,10 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
+// DeadlineKey is the meta carrying the absolute deadline of a request, in
+// unix milliseconds
+const DeadlineKey = "x-deadline"
+
 // Meta type for req
 type Meta map[string]string
 
@@ -50,12 +54,34 @@ func increasePropagationLevel(r interfaces.Request) {
 }
 
 // Merge the meta data
-// Needed for cross service communication
+// Needed for cross service communication. The deadline of the merged request
+// is the earliest of both
 func Merge(from, to interfaces.Request) {
+	deadline, ok := Deadline(to)
 	to.SetMeta(from.GetMeta())
+	if ok {
+		SetDeadline(to, deadline)
+	}
 	increasePropagationLevel(to)
 }
 
+// Deadline of the request, if it carries one
+func Deadline(r interfaces.Request) (time.Time, bool) {
+	ms, err := strconv.ParseInt(r.GetMetaProp(DeadlineKey), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), true
+}
+
+// SetDeadline of the request, unless it already carries an earlier one
+func SetDeadline(r interfaces.Request, deadline time.Time) {
+	if current, ok := Deadline(r); ok && current.Before(deadline) {
+		return
+	}
+	r.SetMetaProp(DeadlineKey, strconv.FormatInt(deadline.UnixNano()/int64(time.Millisecond), 10))
+}
+
 // GetID for req - used for tracing and logging
 func (r Request) GetID() string {
 	return r.GetMetaProp("x-trace-id")
@@ -2,6 +2,7 @@ package request
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,3 +21,23 @@ func TestNewReq(t *testing.T) {
 
 	assert.NotNil(t, req.Meta)
 }
+
+func TestMergeDeadline(t *testing.T) {
+	now := time.Now()
+
+	from := New()
+	SetDeadline(from, now.Add(100*time.Millisecond))
+
+	to := New()
+	Merge(from, to)
+
+	deadline, ok := Deadline(to)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(100*time.Millisecond).UnixNano()/int64(time.Millisecond), deadline.UnixNano()/int64(time.Millisecond))
+
+	SetDeadline(to, now.Add(50*time.Millisecond))
+	Merge(from, to)
+
+	deadline, _ = Deadline(to)
+	assert.Equal(t, now.Add(50*time.Millisecond).UnixNano()/int64(time.Millisecond), deadline.UnixNano()/int64(time.Millisecond))
+}
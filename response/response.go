@@ -12,8 +12,12 @@ type Response struct {
 	// and we do not plan to support json
 	Payload []byte        `json:"-" msgpack:"payload"`
 	Error   *oerror.Error `json:"-" msgpack:"error"`
+	Meta    Meta          `json:"-" msgpack:"meta,omitempty"`
 }
 
+// Meta type for res
+type Meta map[string]string
+
 var codec = msgpack.New()
 
 // New reponse
@@ -43,3 +47,22 @@ func (r *Response) SetError(e *oerror.Error) interfaces.Response {
 	r.Error = e
 	return r
 }
+
+// GetMeta for res
+func (r Response) GetMeta() map[string]string {
+	return r.Meta
+}
+
+// GetMetaProp for res
+func (r Response) GetMetaProp(key string) string {
+	return r.Meta[key]
+}
+
+// SetMetaProp for res
+func (r *Response) SetMetaProp(key, value string) interfaces.Response {
+	if r.Meta == nil {
+		r.Meta = map[string]string{}
+	}
+	r.Meta[key] = value
+	return r
+}
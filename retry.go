@@ -0,0 +1,180 @@
+package orion
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/logger"
+)
+
+const (
+	defaultRetryBackoff    = 10 * time.Millisecond
+	defaultRetryMultiplier = 2
+)
+
+// RetryPolicy for outgoing calls. All of the attempts share the timeout of
+// the request, so a call never takes longer than without retries
+type RetryPolicy struct {
+	// MaxAttempts including the first one
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt. Defaults to 10ms
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. 0 means no cap
+	MaxBackoff time.Duration
+	// Multiplier applied to the backoff after each attempt. Defaults to 2
+	Multiplier float64
+	// Jitter randomizes each backoff by up to the given fraction, e.g. 0.2
+	// waits between 80% and 120% of the backoff
+	Jitter float64
+	// AttemptTimeout limits every attempt, so there is time left to retry
+	// after a timeout. 0 means an attempt can use all of the time left
+	AttemptTimeout time.Duration
+	// RetryableCodes are the error codes worth retrying. Defaults to
+	// ORION_TRANSPORT and ORION_OVERLOADED
+	RetryableCodes []string
+}
+
+func (p RetryPolicy) retryable(code string) bool {
+	codes := p.RetryableCodes
+	if len(codes) == 0 {
+		codes = []string{"ORION_TRANSPORT", "ORION_OVERLOADED"}
+	}
+
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff to wait after the given attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	if backoff <= 0 {
+		backoff = float64(defaultRetryBackoff)
+	}
+
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || backoff < float64(p.MaxBackoff)); i++ {
+		backoff *= multiplier
+	}
+
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(backoff)
+}
+
+type retries struct {
+	mu      sync.RWMutex
+	service *RetryPolicy
+	paths   map[string]RetryPolicy
+}
+
+// Retry every call made by the service according to the policy
+func (s *Service) Retry(policy RetryPolicy) {
+	s.retries.mu.Lock()
+	defer s.retries.mu.Unlock()
+
+	s.retries.service = &policy
+}
+
+// RetryPath retries the calls to the given destination path according to the
+// policy. It takes precedence over the one set with Retry
+func (s *Service) RetryPath(path string, policy RetryPolicy) {
+	s.retries.mu.Lock()
+	defer s.retries.mu.Unlock()
+
+	if s.retries.paths == nil {
+		s.retries.paths = map[string]RetryPolicy{}
+	}
+	s.retries.paths[replaceOmitEmpty(path, "/", ".")] = policy
+}
+
+func (s *Service) retryPolicy(route string) (RetryPolicy, bool) {
+	s.retries.mu.RLock()
+	defer s.retries.mu.RUnlock()
+
+	if policy, ok := s.retries.paths[route]; ok {
+		return policy, true
+	}
+	if s.retries.service != nil {
+		return *s.retries.service, true
+	}
+	return RetryPolicy{}, false
+}
+
+// retrying wraps the invoker with the retry policy of the route. The attempt
+// number is sent in the x-attempt meta and every failed attempt is logged
+// with the x-trace-id of the request
+func (s *Service) retrying(route string, next Invoker) Invoker {
+	policy, ok := s.retryPolicy(route)
+	if !ok || policy.MaxAttempts <= 1 {
+		return next
+	}
+
+	return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+		ctx, cancel := context.WithTimeout(ctx, s.callTimeout(ctx, req))
+		defer cancel()
+
+		// the attempt is only set for this call, so the request can be sent
+		// again later
+		defer setCallMeta(req, "x-attempt", "1")()
+
+		for attempt := 1; ; attempt++ {
+			req.SetMetaProp("x-attempt", strconv.Itoa(attempt))
+			res.SetError(nil)
+
+			attemptCtx, cancelAttempt := ctx, context.CancelFunc(func() {})
+			if policy.AttemptTimeout > 0 {
+				attemptCtx, cancelAttempt = context.WithTimeout(ctx, policy.AttemptTimeout)
+			}
+			next(attemptCtx, req, res)
+			cancelAttempt()
+
+			err := res.GetError()
+			if err == nil {
+				return
+			}
+
+			retry := attempt < policy.MaxAttempts && policy.retryable(err.Code)
+			backoff := policy.backoff(attempt)
+
+			s.Logger.
+				CreateMessage("attempt failed " + req.GetPath()).
+				SetLevel(logger.WARNING).
+				SetID(req.GetID()).
+				SetCode(err.Code).
+				SetMap(map[string]interface{}{
+					"attempt": attempt,
+					"retry":   retry,
+					"error":   err.Message,
+				}).
+				Send()
+
+			if !retry {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+		}
+	}
+}
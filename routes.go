@@ -0,0 +1,155 @@
+package orion
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/gig/orion-go-sdk/logger"
+	"github.com/go-chi/chi"
+)
+
+var levelNames = map[int]string{
+	logger.EMERGENCY: "emergency",
+	logger.ALERT:     "alert",
+	logger.CRITICAL:  "critical",
+	logger.ERROR:     "error",
+	logger.WARNING:   "warning",
+	logger.NOTICE:    "notice",
+	logger.INFO:      "info",
+	logger.DEBUG:     "debug",
+	logger.NONE:      "none",
+}
+
+// RouteInfo describes a route registered by the service
+type RouteInfo struct {
+	Route   string `json:"route"`
+	Version string `json:"version"`
+	// Default is true when the version is also routed without it
+	Default  bool   `json:"default"`
+	Stream   bool   `json:"stream"`
+	LogLevel string `json:"logLevel"`
+	// SharedPool is false when the route runs on a dedicated pool
+	SharedPool bool   `json:"sharedPool"`
+	PoolSize   int    `json:"poolSize"`
+	QueueSize  int    `json:"queueSize"`
+	MaxWait    string `json:"maxWait"`
+	Request    string `json:"request"`
+	Response   string `json:"response"`
+}
+
+// EventInfo describes an event subscription of the service
+type EventInfo struct {
+	Subject string `json:"subject"`
+	Raw     bool   `json:"raw"`
+}
+
+type routes struct {
+	mu     sync.Mutex
+	routes []RouteInfo
+	events []EventInfo
+	// defaults are the versions routed without the version, per route
+	defaults map[string]string
+}
+
+func (r *routes) add(info RouteInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes = append(r.routes, info)
+}
+
+func (r *routes) addEvent(info EventInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, info)
+}
+
+// setDefault version of the route. It returns the current default and false
+// when the route already has one
+func (r *routes) setDefault(route, version string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.defaults[route]; ok {
+		return current, false
+	}
+	if r.defaults == nil {
+		r.defaults = map[string]string{}
+	}
+	r.defaults[route] = version
+	return version, true
+}
+
+// Routes registered by the service, sorted by route
+func (s *Service) Routes() []RouteInfo {
+	s.routes.mu.Lock()
+	defer s.routes.mu.Unlock()
+
+	list := append([]RouteInfo{}, s.routes.routes...)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Route < list[j].Route
+	})
+	return list
+}
+
+// Events the service is subscribed to, sorted by subject
+func (s *Service) Events() []EventInfo {
+	s.routes.mu.Lock()
+	defer s.routes.mu.Unlock()
+
+	list := append([]EventInfo{}, s.routes.events...)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Subject < list[j].Subject
+	})
+	return list
+}
+
+// routeInfo for a route being registered. The request type comes from the
+// factory and the response one from the handler signature
+func (s *Service) routeInfo(route string, opts *HandleOptions, logLevel int, factory Factory, res reflect.Type) RouteInfo {
+	info := RouteInfo{
+		Route:      route,
+		Version:    opts.Version,
+		Default:    opts.DefaultVersion,
+		LogLevel:   levelNames[logLevel],
+		SharedPool: opts.PoolSize <= 0,
+		PoolSize:   s.sharedPool.size,
+		QueueSize:  s.sharedPool.queue,
+		MaxWait:    s.MaxWait.String(),
+		Request:    reflect.TypeOf(factory()).String(),
+	}
+
+	if !info.SharedPool {
+		info.PoolSize = opts.PoolSize
+		info.QueueSize = opts.QueueSize
+	}
+	if opts.MaxWait > 0 {
+		info.MaxWait = opts.MaxWait.String()
+	}
+	if res != nil {
+		info.Response = res.String()
+	}
+
+	return info
+}
+
+// installRoutes exposes the routes and the events of the service on the
+// health HTTP server
+func (s *Service) installRoutes(router chi.Router) {
+	router.Get("/routes", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"service": s.Name,
+			"id":      s.ID,
+			"routes":  s.Routes(),
+			"events":  s.Events(),
+		})
+		if err != nil {
+			s.logHTTPError(r, err)
+		}
+	})
+}
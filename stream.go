@@ -0,0 +1,451 @@
+package orion
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	oerror "github.com/gig/orion-go-sdk/error"
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/logger"
+	"github.com/gig/orion-go-sdk/response"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	defaultStreamWindow = 16
+	// streamWindowKey is the meta with the number of chunks the caller buffers
+	streamWindowKey = "x-stream-window"
+	// streamAckKey is the meta with the subject the caller acknowledges the
+	// chunks on, so it can cancel the handler before the first chunk
+	streamAckKey = "x-stream-ack"
+)
+
+// frame of a stream. An error response decodes into an error frame, so the
+// errors replied before the stream starts (e.g. ORION_OVERLOADED) end it too
+type frame struct {
+	Seq     int           `msgpack:"seq"`
+	Payload []byte        `msgpack:"payload"`
+	Error   *oerror.Error `msgpack:"error"`
+	End     bool          `msgpack:"end"`
+	// Ack is the subject the caller acknowledges the frames on. It is only
+	// sent with the first frame
+	Ack string `msgpack:"ack"`
+}
+
+// streamAck tells the handler how many frames the caller consumed
+type streamAck struct {
+	Received int  `msgpack:"received"`
+	Cancel   bool `msgpack:"cancel"`
+}
+
+// StreamHandler sends the chunks of the response through the writer. The
+// returned error, if any, is sent to the caller after the chunks
+type StreamHandler func(ctx context.Context, req interfaces.Request, w *StreamWriter) *oerror.Error
+
+// StreamWriter sends the chunks of a stream. Only a window of chunks can be
+// waiting for the caller to consume them; Send blocks until there is room
+type StreamWriter struct {
+	codec   interfaces.Codec
+	reply   func([]byte)
+	ack     string
+	window  int
+	idle    time.Duration
+	ctx     context.Context
+	seq     int
+	mu      sync.Mutex
+	removed int
+	acked   chan struct{}
+}
+
+// Send a chunk to the caller. It fails when the caller does not consume the
+// chunks for longer than the request timeout or when it is gone
+func (w *StreamWriter) Send(chunk interface{}) error {
+	payload, err := w.codec.Encode(chunk)
+	if err != nil {
+		return err
+	}
+
+	for w.inFlight() >= w.window {
+		select {
+		case <-w.acked:
+		case <-w.ctx.Done():
+			return w.ctx.Err()
+		case <-time.After(w.idle):
+			return fmt.Errorf("the caller did not consume the stream for %s", w.idle)
+		}
+	}
+
+	return w.send(frame{Payload: payload})
+}
+
+func (w *StreamWriter) inFlight() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.seq - w.removed
+}
+
+func (w *StreamWriter) send(f frame) error {
+	w.mu.Lock()
+	f.Seq = w.seq
+	if f.Seq == 0 {
+		f.Ack = w.ack
+	}
+	if !f.End && f.Error == nil {
+		w.seq++
+	}
+	w.mu.Unlock()
+
+	b, err := w.codec.Encode(f)
+	if err != nil {
+		return err
+	}
+
+	w.reply(b)
+	return nil
+}
+
+func (w *StreamWriter) onAck(a streamAck, cancel context.CancelFunc) {
+	if a.Cancel {
+		cancel()
+		return
+	}
+
+	w.mu.Lock()
+	if a.Received > w.removed {
+		w.removed = a.Received
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.acked <- struct{}{}:
+	default:
+	}
+}
+
+// HandleStream registers a handler that replies with a sequence of chunks
+// instead of a single response. Middleware does not apply to stream handlers
+func (s *Service) HandleStream(path string, handler StreamHandler, factory Factory, options ...HandleOption) {
+	opts := newHandleOptions(options)
+	route, aliases := s.routesFor(path, opts)
+
+	info := s.routeInfo(route, opts, logger.INFO, factory, nil)
+	info.Stream = true
+	s.routes.add(info)
+
+	s.subscribe(route, aliases, opts, func(data []byte, reply func([]byte), received time.Time) {
+		req, ok := s.decodeRequest(data, factory, logger.INFO, reply)
+		if !ok {
+			return
+		}
+
+		ctx, finish, ok := s.begin(route, req, received, reply, s.streamContext)
+		if !ok {
+			return
+		}
+
+		window, err := strconv.Atoi(req.GetMetaProp(streamWindowKey))
+		if err != nil || window <= 0 {
+			window = defaultStreamWindow
+		}
+
+		ack := req.GetMetaProp(streamAckKey)
+		if ack == "" {
+			ack = streamAckSubject()
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		w := &StreamWriter{
+			codec:  s.Codec,
+			reply:  reply,
+			ack:    ack,
+			window: window,
+			idle:   time.Duration(s.getTimeout(req)) * time.Millisecond,
+			ctx:    ctx,
+			acked:  make(chan struct{}, 1),
+		}
+
+		s.Transport.Subscribe(w.ack, "", func(b []byte) {
+			var a streamAck
+			if s.Codec.Decode(b, &a) == nil {
+				w.onAck(a, cancel)
+			}
+		})
+		defer s.unsubscribe(w.ack)
+
+		res := response.New()
+		end := frame{End: true}
+		if streamErr := s.serveStream(ctx, handler, req, w); streamErr != nil {
+			res.SetError(streamErr)
+			end = frame{Error: streamErr}
+
+			s.Logger.
+				CreateMessage(req.GetPath()).
+				SetLevel(logger.INFO).
+				SetID(req.GetID()).
+				SetLineOfCode(streamErr.LOC).
+				SetParams(streamErr).
+				Send()
+		}
+
+		finish(res)
+		w.send(end)
+	})
+}
+
+// serveStream calls the handler, recovering a panic into an ORION_INTERNAL error
+func (s *Service) serveStream(ctx context.Context, handler StreamHandler, req interfaces.Request, w *StreamWriter) (err *oerror.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = oerror.New("ORION_INTERNAL").SetMessage(fmt.Sprint(r))
+
+			s.Logger.
+				CreateMessage("ORION_INTERNAL " + req.GetPath()).
+				SetLevel(logger.ERROR).
+				SetID(req.GetID()).
+				SetMap(map[string]interface{}{
+					"error": err,
+					"stack": string(debug.Stack()),
+				}).
+				Send()
+		}
+	}()
+
+	return handler(ctx, req, w)
+}
+
+// Stream of chunks replied by a stream handler. Iterate it with Next:
+//
+//	stream := svc.CallStream(ctx, req)
+//	defer stream.Close()
+//	for stream.Next() {
+//		var item Item
+//		stream.Decode(&item)
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+type Stream struct {
+	service  *Service
+	ctx      context.Context
+	cancel   context.CancelFunc
+	frames   chan frame
+	idle     time.Duration
+	window   int
+	ack      string
+	seq      int
+	acked    int
+	current  []byte
+	err      *oerror.Error
+	finished bool
+}
+
+// CallStream calls a stream handler. It returns once the first chunk arrived
+// or the call failed: interceptors, metrics and traces cover that part of the
+// call. The request timeout applies to the wait for every chunk while the
+// context bounds the whole stream
+func (s *Service) CallStream(ctx context.Context, req interfaces.Request) *Stream {
+	loc := oerror.GenerateLOC(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	st := &Stream{
+		service: s,
+		ctx:     ctx,
+		cancel:  cancel,
+		frames:  make(chan frame, defaultStreamWindow+1),
+		idle:    time.Duration(s.getTimeout(req)) * time.Millisecond,
+		window:  defaultStreamWindow,
+		ack:     streamAckSubject(),
+	}
+
+	route := replaceOmitEmpty(req.GetPath(), "/", ".")
+	deadline, _ := ctx.Deadline()
+
+	res := response.New()
+	s.sendUntil(ctx, route, req, res, loc, deadline, s.intercepted(route, st.invoker(loc)))
+	if err := res.GetError(); err != nil {
+		st.fail(err)
+	}
+
+	return st
+}
+
+// invoker returns the innermost Invoker of the stream: it sends the request
+// and waits for the first chunk. The chunks are received until the stream is
+// done, regardless of the context of the call
+func (st *Stream) invoker(loc oerror.LineOfCode) Invoker {
+	s := st.service
+
+	return func(ctx context.Context, req interfaces.Request, res interfaces.Response) {
+		defer setCallMeta(req, streamWindowKey, strconv.Itoa(st.window))()
+		defer setCallMeta(req, streamAckKey, st.ack)()
+
+		encoded, err := s.Codec.Encode(req)
+		if err != nil {
+			res.SetError(oerror.New("ORION_ENCODE").SetMessage(err.Error()).SetLineOfCode(loc))
+			return
+		}
+
+		path := replaceOmitEmpty(req.GetPath(), "/", ".")
+		started := make(chan *oerror.Error, 1)
+
+		go func() {
+			defer close(st.frames)
+			defer close(started)
+
+			first := true
+			err := s.requestMany(st.ctx, path, encoded, func(b []byte) bool {
+				var f frame
+				if err := s.Codec.Decode(b, &f); err != nil {
+					f = frame{Error: oerror.New("ORION_DECODE").SetMessage(err.Error())}
+				}
+
+				if first {
+					first = false
+					started <- f.Error
+				}
+
+				select {
+				case st.frames <- f:
+					return !f.End && f.Error == nil
+				case <-st.ctx.Done():
+					return false
+				}
+			})
+			if err != nil && st.ctx.Err() == nil {
+				e := oerror.New("ORION_TRANSPORT").SetMessage(err.Error())
+				if first {
+					started <- e
+				}
+				select {
+				case st.frames <- frame{Error: e}:
+				default:
+				}
+			}
+		}()
+
+		timer := time.NewTimer(st.idle)
+		defer timer.Stop()
+
+		select {
+		case err, ok := <-started:
+			switch {
+			case !ok && st.ctx.Err() != nil:
+				res.SetError(oerror.New("ORION_CANCELED").SetMessage(st.ctx.Err().Error()).SetLineOfCode(loc))
+			case !ok:
+				res.SetError(oerror.New("ORION_TRANSPORT").SetMessage("the stream ended unexpectedly").SetLineOfCode(loc))
+			case err != nil:
+				res.SetError(err)
+			}
+		case <-ctx.Done():
+			code := "ORION_TRANSPORT"
+			if ctx.Err() == context.Canceled {
+				code = "ORION_CANCELED"
+			}
+			res.SetError(oerror.New(code).SetMessage(ctx.Err().Error()).SetLineOfCode(loc))
+		case <-timer.C:
+			res.SetError(oerror.New("ORION_TRANSPORT").SetMessage("timed out waiting for the first chunk of the stream").SetLineOfCode(loc))
+		}
+	}
+}
+
+// streamAckSubject returns a new subject to acknowledge the chunks of a stream
+func streamAckSubject() string {
+	uid, _ := uuid.NewV4()
+	return "_STREAM." + uid.String()
+}
+
+// Next waits for the next chunk. It returns false at the end of the stream or
+// when it failed, see Err
+func (st *Stream) Next() bool {
+	if st.finished {
+		return false
+	}
+
+	timer := time.NewTimer(st.idle)
+	defer timer.Stop()
+
+	select {
+	case f, ok := <-st.frames:
+		switch {
+		case !ok:
+			st.fail(oerror.New("ORION_TRANSPORT").SetMessage("the stream ended unexpectedly"))
+		case f.Error != nil:
+			st.fail(f.Error)
+		case f.Seq != st.seq:
+			st.fail(oerror.New("ORION_TRANSPORT").SetMessage("chunk " + strconv.Itoa(st.seq) + " of the stream was lost"))
+		case f.End:
+			st.finish()
+		default:
+			if f.Ack != "" {
+				st.ack = f.Ack
+			}
+			st.seq++
+			st.current = f.Payload
+			st.acknowledge(false)
+			return true
+		}
+	case <-st.ctx.Done():
+		code := "ORION_TRANSPORT"
+		if st.ctx.Err() == context.Canceled {
+			code = "ORION_CANCELED"
+		}
+		st.fail(oerror.New(code).SetMessage(st.ctx.Err().Error()))
+	case <-timer.C:
+		st.fail(oerror.New("ORION_TRANSPORT").SetMessage("timed out waiting for the next chunk of the stream"))
+	}
+
+	return false
+}
+
+// Decode the current chunk
+func (st *Stream) Decode(to interface{}) error {
+	return st.service.Codec.Decode(st.current, to)
+}
+
+// Err that ended the stream, if any
+func (st *Stream) Err() *oerror.Error {
+	return st.err
+}
+
+// Close the stream. When it did not reach its end, the handler is cancelled
+func (st *Stream) Close() {
+	if !st.finished {
+		st.acknowledge(true)
+		st.finish()
+	}
+}
+
+// acknowledge the consumed chunks every half window, so the handler can keep
+// sending while the caller consumes
+func (st *Stream) acknowledge(cancel bool) {
+	if st.ack == "" || (!cancel && st.seq-st.acked < (st.window+1)/2) {
+		return
+	}
+
+	b, err := st.service.Codec.Encode(streamAck{Received: st.seq, Cancel: cancel})
+	if err != nil {
+		return
+	}
+
+	st.service.Transport.Publish(st.ack, b)
+	st.acked = st.seq
+}
+
+func (st *Stream) fail(err *oerror.Error) {
+	st.err = err
+	st.acknowledge(true)
+	st.finish()
+}
+
+func (st *Stream) finish() {
+	st.finished = true
+	st.current = nil
+	st.cancel()
+}
@@ -0,0 +1,57 @@
+package orion
+
+import (
+	"context"
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/tracing"
+)
+
+// startServerSpan for a request received by the route. The traceparent meta
+// of the request is replaced by the span, so the requests merged from it with
+// request.Merge are its children
+func (s *Service) startServerSpan(route string, req interfaces.Request, received time.Time) *tracing.Span {
+	parent, _ := tracing.ParseTraceparent(req.GetMetaProp(tracing.TraceparentKey))
+
+	span := s.Tracer.Start(route, tracing.Server, parent, req.GetID())
+	span.
+		SetAttribute("orion.path", req.GetPath()).
+		SetAttribute("orion.trace_id", req.GetID()).
+		SetAttribute("orion.queue_ms", int64(span.Start.Sub(received)/time.Millisecond))
+
+	req.SetMetaProp(tracing.TraceparentKey, span.Context().Traceparent())
+	return span
+}
+
+// startClientSpan for a call to the route. The caller sets it as the
+// traceparent meta of the request for the duration of the call
+func (s *Service) startClientSpan(ctx context.Context, route string, req interfaces.Request) *tracing.Span {
+	span := s.Tracer.Start(route, tracing.Client, parentSpan(ctx, req), req.GetID())
+	span.
+		SetAttribute("orion.path", req.GetPath()).
+		SetAttribute("orion.trace_id", req.GetID())
+
+	return span
+}
+
+// parentSpan of an outgoing message: the span carried by the context or,
+// failing that, the traceparent meta of the request, if any
+func parentSpan(ctx context.Context, req interfaces.Request) tracing.SpanContext {
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		return span.Context()
+	}
+	if req == nil {
+		return tracing.SpanContext{}
+	}
+	parent, _ := tracing.ParseTraceparent(req.GetMetaProp(tracing.TraceparentKey))
+	return parent
+}
+
+// finishSpan with the error of the response, if any
+func finishSpan(span *tracing.Span, res interfaces.Response) {
+	if err := res.GetError(); err != nil {
+		span.SetError(err.Code, err.Message)
+	}
+	span.Finish()
+}
@@ -0,0 +1,236 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 512
+	defaultFlushInterval = 5 * time.Second
+)
+
+// StdoutExporter writes every span as a JSON line
+type StdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutExporter writing to w, usually os.Stdout
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{w: w}
+}
+
+// Export the span
+func (e *StdoutExporter) Export(span *Span) {
+	span.mu.Lock()
+	b, err := json.Marshal(span)
+	span.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.w.Write(append(b, '\n'))
+}
+
+// Close does nothing, the spans are written right away
+func (e *StdoutExporter) Close() error {
+	return nil
+}
+
+// OTLPExporter sends the spans in batches to an OpenTelemetry collector
+// using OTLP over HTTP with the JSON encoding
+type OTLPExporter struct {
+	endpoint string
+	client   *http.Client
+	spans    chan *Span
+	flush    chan chan error
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewOTLPExporter for the traces endpoint of the collector, e.g.
+// http://localhost:4318/v1/traces. Spans are sent every 5s or once 512 of
+// them are buffered. Spans are dropped when the buffer is full
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	e := &OTLPExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan *Span, defaultBatchSize*2),
+		flush:    make(chan chan error),
+		done:     make(chan struct{}),
+	}
+
+	go e.loop()
+	return e
+}
+
+// Export queues the span
+func (e *OTLPExporter) Export(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+	}
+}
+
+// Close sends the queued spans and stops the exporter
+func (e *OTLPExporter) Close() error {
+	var err error
+	e.once.Do(func() {
+		result := make(chan error)
+		e.flush <- result
+		err = <-result
+		close(e.done)
+	})
+	return err
+}
+
+func (e *OTLPExporter) loop() {
+	ticker := time.NewTicker(defaultFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, defaultBatchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := e.send(batch)
+		batch = make([]*Span, 0, defaultBatchSize)
+		return err
+	}
+
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= defaultBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case result := <-e.flush:
+			for len(e.spans) > 0 {
+				batch = append(batch, <-e.spans)
+			}
+			result <- send()
+		case <-e.done:
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) send(spans []*Span) error {
+	b, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	res, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("otlp export failed with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// OTLP span kinds and status codes
+var otlpKinds = map[string]int{
+	Server:   2,
+	Client:   3,
+	Producer: 4,
+}
+
+const (
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// otlpRequest groups the spans by service, as OTLP resources
+func otlpRequest(spans []*Span) map[string]interface{} {
+	byService := map[string][]interface{}{}
+	services := []string{}
+
+	for _, span := range spans {
+		span.mu.Lock()
+
+		status := map[string]interface{}{"code": otlpStatusOK}
+		if span.ErrorCode != "" {
+			status = map[string]interface{}{
+				"code":    otlpStatusError,
+				"message": span.ErrorCode + ": " + span.ErrorMessage,
+			}
+		}
+
+		attributes := []otlpAttribute{}
+		for key, value := range span.Attributes {
+			attributes = append(attributes, otlpAttribute{Key: key, Value: otlpValue(value)})
+		}
+
+		if _, ok := byService[span.Service]; !ok {
+			services = append(services, span.Service)
+		}
+		byService[span.Service] = append(byService[span.Service], map[string]interface{}{
+			"traceId":           span.TraceID,
+			"spanId":            span.SpanID,
+			"parentSpanId":      span.ParentID,
+			"name":              span.Name,
+			"kind":              otlpKinds[span.Kind],
+			"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+			"attributes":        attributes,
+			"status":            status,
+		})
+
+		span.mu.Unlock()
+	}
+
+	resourceSpans := []interface{}{}
+	for _, service := range services {
+		resourceSpans = append(resourceSpans, map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpAttribute{{Key: "service.name", Value: otlpValue(service)}},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "orion-go-sdk"},
+				"spans": byService[service],
+			}},
+		})
+	}
+
+	return map[string]interface{}{"resourceSpans": resourceSpans}
+}
+
+func otlpValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
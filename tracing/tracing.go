@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentKey is the meta carrying the W3C trace context of a request
+const TraceparentKey = "traceparent"
+
+// Span kinds
+const (
+	Server   = "server"
+	Client   = "client"
+	Producer = "producer"
+)
+
+// SpanContext identifies a span across services
+type SpanContext struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+// Valid reports whether the context has both IDs
+func (c SpanContext) Valid() bool {
+	return len(c.TraceID) == 32 && len(c.SpanID) == 16
+}
+
+// Traceparent formats the context as a W3C traceparent header
+func (c SpanContext) Traceparent() string {
+	return "00-" + c.TraceID + "-" + c.SpanID + "-01"
+}
+
+// ParseTraceparent parses a W3C traceparent header
+func ParseTraceparent(traceparent string) (SpanContext, bool) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+
+	c := SpanContext{TraceID: parts[1], SpanID: parts[2]}
+	if !c.Valid() || !isHex(c.TraceID) || !isHex(c.SpanID) ||
+		c.TraceID == strings.Repeat("0", 32) || c.SpanID == strings.Repeat("0", 16) {
+		return SpanContext{}, false
+	}
+	return c, true
+}
+
+// TraceIDFrom returns the W3C trace ID for an orion x-trace-id. UUIDs keep
+// their digits so both IDs can be correlated, anything else gets a new ID
+func TraceIDFrom(id string) string {
+	id = strings.ToLower(strings.Replace(id, "-", "", -1))
+	if len(id) == 32 && isHex(id) && id != strings.Repeat("0", 32) {
+		return id
+	}
+	return randomHex(16)
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Span of work done by a service
+type Span struct {
+	SpanContext
+	ParentID   string                 `json:"parentId,omitempty"`
+	Service    string                 `json:"service"`
+	Name       string                 `json:"name"`
+	Kind       string                 `json:"kind"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// ErrorCode is set when the span failed
+	ErrorCode    string `json:"errorCode,omitempty"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	mu     sync.Mutex
+	tracer *Tracer
+	ended  bool
+}
+
+// Context of the span, to be propagated
+func (s *Span) Context() SpanContext {
+	return s.SpanContext
+}
+
+// SetAttribute of the span
+func (s *Span) SetAttribute(key string, value interface{}) *Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Attributes == nil {
+		s.Attributes = map[string]interface{}{}
+	}
+	s.Attributes[key] = value
+	return s
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(code, message string) *Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ErrorCode = code
+	s.ErrorMessage = message
+	return s
+}
+
+// Finish the span and export it. Only the first call has effect
+func (s *Span) Finish() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+
+	if s.tracer != nil && s.tracer.exporter != nil {
+		s.tracer.exporter.Export(s)
+	}
+}
+
+// Exporter sends the finished spans somewhere. Export is called on the
+// request path, so it should not block
+type Exporter interface {
+	Export(span *Span)
+	Close() error
+}
+
+// Tracer starts the spans of a service
+type Tracer struct {
+	service  string
+	exporter Exporter
+}
+
+// NewTracer for the service. With a nil exporter the spans are only
+// propagated
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{service: service, exporter: exporter}
+}
+
+// Start a span. Without a valid parent it starts a new trace with traceID,
+// see TraceIDFrom
+func (t *Tracer) Start(name, kind string, parent SpanContext, traceID string) *Span {
+	span := &Span{
+		Service: t.service,
+		Name:    name,
+		Kind:    kind,
+		Start:   time.Now(),
+		tracer:  t,
+	}
+
+	if parent.Valid() {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		span.TraceID = TraceIDFrom(traceID)
+	}
+	span.SpanID = randomHex(8)
+
+	return span
+}
+
+// Close the exporter, flushing the spans not exported yet
+func (t *Tracer) Close() error {
+	if t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Close()
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of the context carrying the span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by the context, if any
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
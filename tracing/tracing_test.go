@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceparent(t *testing.T) {
+	c, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", c.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", c.SpanID)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", c.Traceparent())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		_, ok := ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+
+	assert.Equal(t, "6ba7b8109dad11d180b400c04fd430c8", TraceIDFrom("6ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+	assert.Len(t, TraceIDFrom("custom-id"), 32)
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer("calc", NewStdoutExporter(&buf))
+
+	parent := tracer.Start("calc.add", Server, SpanContext{}, "")
+	child := tracer.Start("calc.sum", Client, parent.Context(), "")
+	child.SetError("ORION_TRANSPORT", "timeout")
+	child.Finish()
+	child.Finish()
+
+	var span map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &span))
+	assert.Equal(t, parent.TraceID, span["traceId"])
+	assert.Equal(t, parent.SpanID, span["parentId"])
+	assert.Equal(t, "calc", span["service"])
+	assert.Equal(t, "ORION_TRANSPORT", span["errorCode"])
+}
+
+func TestOTLPExporter(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(b, &body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("calc", NewOTLPExporter(server.URL))
+	tracer.Start("calc.add", Server, SpanContext{}, "").SetAttribute("orion.path", "/calc/add").Finish()
+	assert.Nil(t, tracer.Close())
+
+	body := <-bodies
+	resource := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	spans := resource["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "calc.add", spans[0].(map[string]interface{})["name"])
+	assert.Equal(t, float64(2), spans[0].(map[string]interface{})["kind"])
+}
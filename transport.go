@@ -0,0 +1,77 @@
+package orion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gig/orion-go-sdk/interfaces"
+)
+
+var errRequestManyNotSupported = errors.New("the transport does not support requests with many replies")
+
+// request sends the payload and waits for the reply until the context is
+// done. Transports that are not a ContextRequester are sent a plain Request
+// with the time left until the deadline of the context
+func (s *Service) request(ctx context.Context, path string, payload []byte) ([]byte, error) {
+	if t, ok := s.Transport.(interfaces.ContextRequester); ok {
+		return t.RequestWithContext(ctx, path, payload)
+	}
+
+	timeout := time.Duration(s.Timeout) * time.Millisecond
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := s.Transport.Request(path, payload, int(timeout/time.Millisecond))
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// requestMany calls the handler with every reply to the request, see
+// interfaces.MultiRequester
+func (s *Service) requestMany(ctx context.Context, path string, payload []byte, handler func([]byte) bool) error {
+	t, ok := s.Transport.(interfaces.MultiRequester)
+	if !ok {
+		return errRequestManyNotSupported
+	}
+	return t.RequestMany(ctx, path, payload, handler)
+}
+
+// unsubscribe the subject, when the transport supports it
+func (s *Service) unsubscribe(subject string) error {
+	if t, ok := s.Transport.(interfaces.Unsubscriber); ok {
+		return t.Unsubscribe(subject)
+	}
+	return nil
+}
+
+// drainTransport stops the delivery of new requests, when the transport
+// supports it. It returns how many requests were dropped
+func (s *Service) drainTransport() (int, error) {
+	if t, ok := s.Transport.(interfaces.Drainer); ok {
+		return t.Drain()
+	}
+	return 0, nil
+}
+
+// flushTransport sends the buffered messages, when the transport buffers them
+func (s *Service) flushTransport() error {
+	if t, ok := s.Transport.(interfaces.Flusher); ok {
+		return t.Flush()
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -168,6 +170,42 @@ func (t *Transport) Request(path string, payload []byte, timeOut int) ([]byte, e
 	return nil, nil
 }
 
+var errRPCNotSupported = errors.New("not supported by the kafka transport")
+
+// RequestWithContext is not supported by the kafka transport
+func (t *Transport) RequestWithContext(ctx context.Context, path string, payload []byte) ([]byte, error) {
+	return nil, errRPCNotSupported
+}
+
+// RequestMany is not supported by the kafka transport
+func (t *Transport) RequestMany(ctx context.Context, path string, payload []byte, handler func([]byte) bool) error {
+	return errRPCNotSupported
+}
+
+// Unsubscribe removes the handler for the topic. It only takes effect before
+// the transport starts listening
+func (t *Transport) Unsubscribe(topic string) error {
+	topic = normalizeTopic(topic)
+	delete(t.handlers, topic)
+	delete(t.rawMsgHandlers, topic)
+	return nil
+}
+
+// Drain stops polling for new messages. The kafka transport does not handle
+// requests, so none is dropped
+func (t *Transport) Drain() (int, error) {
+	t.listening = false
+	return 0, nil
+}
+
+// Flush waits for the produced messages to be delivered
+func (t *Transport) Flush() error {
+	if remaining := t.options.Producer.Flush(1000); remaining > 0 {
+		return fmt.Errorf("%d messages were not delivered", remaining)
+	}
+	return nil
+}
+
 // Close connection
 func (t *Transport) Close() {
 	go func() {
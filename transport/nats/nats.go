@@ -1,9 +1,11 @@
 package nats
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -22,6 +24,10 @@ type Transport struct {
 	close        chan struct{}
 	closeHandler func(*nats.Conn)
 	open         bool
+	subsMutex    sync.Mutex
+	subs         []*nats.Subscription
+	// routeSubs are the subscriptions of Handle, the ones Drain removes
+	routeSubs []*nats.Subscription
 }
 
 // New returns client for NATS messaging
@@ -84,32 +90,59 @@ func (t *Transport) Publish(topic string, data []byte) error {
 	return err
 }
 
-// Subscribe for topic
+// Subscribe for topic. With an empty group every subscriber receives the
+// messages, otherwise only one of the group does
 func (t *Transport) Subscribe(topic string, group string, handler func([]byte)) error {
-	_, err := t.conn.QueueSubscribe(topic, group, func(msg *nats.Msg) {
+	sub, err := t.conn.QueueSubscribe(topic, group, func(msg *nats.Msg) {
 		handler(msg.Data)
 	})
 	t.handleUnexpectedClose(err)
+	t.track(sub)
 	return err
 }
 
+// SubscribeSingle works the same as Subscribe. The returned func removes only
+// this subscription
+func (t *Transport) SubscribeSingle(topic string, group string, handler func([]byte)) (func() error, error) {
+	sub, err := t.conn.QueueSubscribe(topic, group, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+	t.handleUnexpectedClose(err)
+	if err != nil {
+		return nil, err
+	}
+	t.track(sub)
+
+	return func() error {
+		t.subsMutex.Lock()
+		t.subs = untrack(t.subs, sub)
+		t.subsMutex.Unlock()
+
+		err := sub.Unsubscribe()
+		t.handleUnexpectedClose(err)
+		return err
+	}, nil
+}
+
 // SubscribeForRawMsg for topic
 func (t *Transport) SubscribeForRawMsg(topic string, group string, handler func(interface{})) error {
-	_, err := t.conn.QueueSubscribe(topic, group, func(msg *nats.Msg) {
+	sub, err := t.conn.QueueSubscribe(topic, group, func(msg *nats.Msg) {
 		handler(msg)
 	})
 	t.handleUnexpectedClose(err)
+	t.track(sub)
 	return err
 }
 
 // Handle path
 func (t *Transport) Handle(path string, group string, handler func([]byte, func([]byte))) error {
-	_, err := t.conn.QueueSubscribe(path, group, func(msg *nats.Msg) {
+	sub, err := t.conn.QueueSubscribe(path, group, func(msg *nats.Msg) {
 		handler(msg.Data, func(res []byte) {
 			t.conn.Publish(msg.Reply, res)
 		})
 	})
 	t.handleUnexpectedClose(err)
+	t.trackRoute(sub)
 	return err
 }
 
@@ -125,6 +158,122 @@ func (t *Transport) Request(path string, payload []byte, timeOut int) ([]byte, e
 	return data, err
 }
 
+// RequestWithContext path. The request is aborted once the context is done
+func (t *Transport) RequestWithContext(ctx context.Context, path string, payload []byte) ([]byte, error) {
+	msg, err := t.conn.RequestWithContext(ctx, path, payload)
+	t.handleUnexpectedClose(err)
+	var data []byte
+	if msg != nil {
+		data = msg.Data
+	}
+	return data, err
+}
+
+// Drain removes the subscriptions of Handle, so no new requests are
+// delivered. The other subscriptions and the connection stay open, so the
+// requests being processed can still use them and be replied. It returns how
+// many requests were delivered to the client but dropped before being handled
+func (t *Transport) Drain() (int, error) {
+	t.subsMutex.Lock()
+	defer t.subsMutex.Unlock()
+
+	var err error
+	dropped := 0
+	for _, sub := range t.routeSubs {
+		if pending, _, e := sub.Pending(); e == nil {
+			dropped += pending
+		}
+		if e := sub.Unsubscribe(); e != nil {
+			err = e
+		}
+	}
+	t.routeSubs = nil
+
+	t.handleUnexpectedClose(err)
+	return dropped, err
+}
+
+// Flush the pending messages to the server
+func (t *Transport) Flush() error {
+	err := t.conn.Flush()
+	t.handleUnexpectedClose(err)
+	return err
+}
+
+// RequestMany publishes the request and calls the handler with every reply
+// until the handler returns false or the context is done
+func (t *Transport) RequestMany(ctx context.Context, path string, payload []byte, handler func([]byte) bool) error {
+	inbox := nats.NewInbox()
+
+	sub, err := t.conn.SubscribeSync(inbox)
+	if err != nil {
+		t.handleUnexpectedClose(err)
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	err = t.conn.PublishRequest(path, inbox, payload)
+	if err != nil {
+		t.handleUnexpectedClose(err)
+		return err
+	}
+
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			t.handleUnexpectedClose(err)
+			return err
+		}
+		if !handler(msg.Data) {
+			return nil
+		}
+	}
+}
+
+// Unsubscribe removes the subscriptions for the subject
+func (t *Transport) Unsubscribe(subject string) error {
+	t.subsMutex.Lock()
+	defer t.subsMutex.Unlock()
+
+	var err error
+	t.subs, err = unsubscribe(t.subs, subject)
+	var routeErr error
+	t.routeSubs, routeErr = unsubscribe(t.routeSubs, subject)
+	if routeErr != nil {
+		err = routeErr
+	}
+
+	t.handleUnexpectedClose(err)
+	return err
+}
+
+// unsubscribe the subscriptions to the subject and return the rest
+func unsubscribe(subs []*nats.Subscription, subject string) ([]*nats.Subscription, error) {
+	var err error
+	kept := subs[:0]
+	for _, sub := range subs {
+		if sub.Subject != subject {
+			kept = append(kept, sub)
+			continue
+		}
+		if e := sub.Unsubscribe(); e != nil {
+			err = e
+		}
+	}
+	return kept, err
+}
+
+// untrack the subscription and return the rest
+func untrack(subs []*nats.Subscription, sub *nats.Subscription) []*nats.Subscription {
+	kept := subs[:0]
+	for _, s := range subs {
+		if s != sub {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
 // Close connection
 func (t *Transport) Close() {
 	go func() {
@@ -140,6 +289,24 @@ func (t *Transport) OnClose(handler interface{}) {
 	}
 }
 
+func (t *Transport) track(sub *nats.Subscription) {
+	if sub == nil {
+		return
+	}
+	t.subsMutex.Lock()
+	t.subs = append(t.subs, sub)
+	t.subsMutex.Unlock()
+}
+
+func (t *Transport) trackRoute(sub *nats.Subscription) {
+	if sub == nil {
+		return
+	}
+	t.subsMutex.Lock()
+	t.routeSubs = append(t.routeSubs, sub)
+	t.subsMutex.Unlock()
+}
+
 func (t *Transport) handleUnexpectedClose(err error) {
 	if err == nats.ErrConnectionClosed {
 		t.open = false
@@ -0,0 +1,84 @@
+//go:build go1.18
+// +build go1.18
+
+package orion
+
+import (
+	"context"
+	"reflect"
+
+	oerror "github.com/gig/orion-go-sdk/error"
+	"github.com/gig/orion-go-sdk/interfaces"
+	"github.com/gig/orion-go-sdk/logger"
+)
+
+// requestOf is satisfied by *T when it implements interfaces.Request, so the
+// typed API can allocate the request without a factory
+type requestOf[T any] interface {
+	*T
+	interfaces.Request
+}
+
+// responseOf is satisfied by *T when it implements interfaces.Response
+type responseOf[T any] interface {
+	*T
+	interfaces.Response
+}
+
+// HandleTyped works the same as Handle, but the handler signature is checked
+// at compile time and it is called without reflection. The request is
+// allocated from its type, so there is no factory:
+//
+//	orion.HandleTyped(svc, "add", func(ctx context.Context, req *addReq) *addRes {
+//		return &addRes{Payload: addPayload{Result: req.Params.A + req.Params.B}}
+//	})
+//
+// Handlers without a context are wrapped with NoContext
+func HandleTyped[Req any, Res interfaces.Response, PReq requestOf[Req]](s *Service, path string, handler func(context.Context, PReq) Res, options ...HandleOption) {
+	handleTyped(s, path, logger.INFO, handler, options)
+}
+
+// HandleTypedWithoutLogging works the same as HandleTyped but with disabled
+// logging
+func HandleTypedWithoutLogging[Req any, Res interfaces.Response, PReq requestOf[Req]](s *Service, path string, handler func(context.Context, PReq) Res, options ...HandleOption) {
+	handleTyped(s, path, logger.NONE, handler, options)
+}
+
+// HandleTypedWithCustomLogLevel works the same as HandleTyped but it lets you
+// set the log level
+func HandleTypedWithCustomLogLevel[Req any, Res interfaces.Response, PReq requestOf[Req]](s *Service, path string, logLevel int, handler func(context.Context, PReq) Res, options ...HandleOption) {
+	handleTyped(s, path, logLevel, handler, options)
+}
+
+func handleTyped[Req any, Res interfaces.Response, PReq requestOf[Req]](s *Service, path string, logLevel int, handler func(context.Context, PReq) Res, options []HandleOption) {
+	factory := func() interfaces.Request {
+		return PReq(new(Req))
+	}
+
+	typed := func(ctx context.Context, req interfaces.Request) interfaces.Response {
+		return handler(ctx, req.(PReq))
+	}
+
+	s.register(path, logLevel, typed, factory, reflect.TypeOf((*Res)(nil)).Elem(), options)
+}
+
+// NoContext adapts a handler without a context to the typed API:
+//
+//	orion.HandleTyped(svc, "add", orion.NoContext(func(req *addReq) *addRes {
+//		...
+//	}))
+func NoContext[PReq any, Res any](handler func(PReq) Res) func(context.Context, PReq) Res {
+	return func(_ context.Context, req PReq) Res {
+		return handler(req)
+	}
+}
+
+// CallTyped works the same as CallContext, but it allocates the response and
+// returns it with its concrete type:
+//
+//	res := orion.CallTyped[addRes](ctx, svc, req)
+func CallTyped[Res any, PRes responseOf[Res]](ctx context.Context, s *Service, req interfaces.Request, options ...CallOption) PRes {
+	res := PRes(new(Res))
+	s.call(ctx, req, res, oerror.GenerateLOC(1), options...)
+	return res
+}
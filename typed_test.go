@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+package orion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTyped(t *testing.T) {
+	type params struct {
+		A int
+		B int
+	}
+
+	type sumReq struct {
+		Request
+		Params params
+	}
+
+	type sumRes struct {
+		Response
+		Payload int
+	}
+
+	done := make(chan []*sumRes)
+
+	typed := New("typed", DisableHealthChecks)
+
+	HandleTyped(typed, "sum", func(ctx context.Context, req *sumReq) *sumRes {
+		return &sumRes{Payload: req.Params.A + req.Params.B}
+	})
+	HandleTypedWithoutLogging(typed, "diff", NoContext(func(req *sumReq) *sumRes {
+		return &sumRes{Payload: req.Params.A - req.Params.B}
+	}))
+
+	go typed.Listen(func() {
+		var results []*sumRes
+
+		req := &sumReq{Params: params{A: 1, B: 2}}
+		req.SetPath("/typed/sum")
+		results = append(results, CallTyped[sumRes](context.Background(), svc, req))
+
+		req = &sumReq{Params: params{A: 1, B: 2}}
+		req.SetPath("/typed/diff")
+		results = append(results, CallTyped[sumRes](context.Background(), svc, req, ToInstance(typed.ID)))
+
+		typed.Close()
+
+		done <- results
+	})
+
+	results := <-done
+	assert.Nil(t, results[0].GetError())
+	assert.Equal(t, 3, results[0].Payload)
+	assert.Nil(t, results[1].GetError())
+	assert.Equal(t, -1, results[1].Payload)
+}